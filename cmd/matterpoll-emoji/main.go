@@ -1,25 +1,110 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/kaakaa/matterpoll-emoji/poll"
 )
 
-var config = flag.String("c", "config.json", "optional path to the config file")
+var (
+	// Version and Revision are set by the Makefile
+	Version  = "dev"
+	Revision = ""
+
+	config = flag.String("c", "config.json", "optional path to the config file")
+	format = flag.String("format", "", "format of the config file: json, yaml or toml. Detected from the file extension by default")
+)
 
 func main() {
 	log.SetFlags(log.Ldate | log.Ltime | log.Lshortfile)
+	poll.Version = Version
+	if len(Revision) != 0 {
+		poll.Version += " (" + Revision + ")"
+	}
+
+	loader := &poll.ConfLoader{LookupEnv: os.LookupEnv}
+	loader.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+	loader.Path = *config
+	loader.Format = *format
+	// The default config file may be missing, if all settings are given by environment variables or flags
+	loader.Optional = !isFlagSet("c")
 
-	c, err := poll.LoadConf(*config)
+	c, err := loader.Load()
 	if err != nil {
 		log.Fatal(err)
 	}
-	ps := poll.Server{Conf: c}
-	http.HandleFunc("/poll", ps.Cmd)
-	if err := http.ListenAndServe(c.Listen, nil); err != nil {
+	ps, err := poll.NewServer(c)
+	if err != nil {
 		log.Fatal(err)
 	}
+	ps.Start()
+	go reload(loader, ps)
+	http.HandleFunc(poll.CommandPath, ps.Cmd)
+	http.HandleFunc(poll.CommandPath+"/", ps.Cmd)
+	http.HandleFunc(poll.VotePath, ps.Vote)
+	http.HandleFunc(poll.MetricsPath, ps.Metrics)
+	http.HandleFunc(poll.HealthzPath, ps.Healthz)
+	http.HandleFunc(poll.ReadyzPath, ps.Readyz)
+	srv := &http.Server{Addr: c.Listen}
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			ps.Logger().Error("Failed to listen for requests", "listen", c.Listen, "err", err)
+			os.Exit(1)
+		}
+	}()
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGTERM, os.Interrupt)
+	<-stop
+	shutdown(srv, ps)
+}
+
+// shutdown waits for running requests and the background work of ps to finish, but not longer than the configured timeout
+func shutdown(srv *http.Server, ps *poll.Server) {
+	lg := ps.Logger()
+	timeout := time.Duration(ps.Conf().ShutdownTimeout)
+	lg.Info("Shutting down, waiting for running work", "timeout", timeout)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		lg.Error("Failed to finish running requests", "err", err)
+	}
+	if err := ps.Shutdown(ctx); err != nil {
+		lg.Error("Failed to finish background work", "err", err)
+		return
+	}
+	lg.Info("Shutdown complete")
+}
+
+func isFlagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// reload loads the config again on SIGHUP. The old config is kept, if the new one is invalid.
+func reload(loader *poll.ConfLoader, ps *poll.Server) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	for range hup {
+		c, err := loader.Load()
+		if err != nil {
+			ps.Logger().Error("Failed to reload config, keeping the old one", "err", err)
+			continue
+		}
+		ps.Logger().Info("Config reloaded")
+		ps.SetConf(c)
+	}
 }
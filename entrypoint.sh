@@ -1,17 +1,12 @@
-#!/bin/bash -xe
-
-MATTERMOST_URL=${MATTERMOST_URL:-http://mattermost.example.com:8086}
-MATTERMOST_TOKEN=${MATTERMOST_TOKEN:-api_token}
-MATTERMOST_USER=${MATTERMOST_USER:-user}
-MATTERMOST_PASSWORD=${MATTERMOST_PASSWORD:-password}
-PORT=${PORT:-8505}
-
-CONFIG=config.json
-
-jq ".listen = \"0.0.0.0:${PORT}\"" $CONFIG > $CONFIG.tmp && mv $CONFIG.tmp $CONFIG
-jq ".host = \"${MATTERMOST_URL}\"" $CONFIG > $CONFIG.tmp && mv $CONFIG.tmp $CONFIG
-jq ".token = \"${MATTERMOST_TOKEN}\"" $CONFIG > $CONFIG.tmp && mv $CONFIG.tmp $CONFIG
-jq ".user.id = \"${MATTERMOST_USER}\"" $CONFIG > $CONFIG.tmp && mv $CONFIG.tmp $CONFIG
-jq ".user.password = \"${MATTERMOST_PASSWORD}\"" $CONFIG > $CONFIG.tmp && mv $CONFIG.tmp $CONFIG
-
-./matterpoll-emoji
+#!/bin/bash -e
+
+# Matterpoll reads its settings from MATTERPOLL_* environment variables, e.g. MATTERPOLL_HOST.
+# The variables of older images are still supported.
+export MATTERPOLL_HOST=${MATTERPOLL_HOST:-${MATTERMOST_URL}}
+export MATTERPOLL_TOKEN=${MATTERPOLL_TOKEN:-${MATTERMOST_TOKEN}}
+export MATTERPOLL_USER_ID=${MATTERPOLL_USER_ID:-${MATTERMOST_USER}}
+export MATTERPOLL_USER_PASSWORD=${MATTERPOLL_USER_PASSWORD:-${MATTERMOST_PASSWORD}}
+export MATTERPOLL_ACCESS_TOKEN=${MATTERPOLL_ACCESS_TOKEN:-${MATTERMOST_ACCESS_TOKEN}}
+export MATTERPOLL_LISTEN=${MATTERPOLL_LISTEN:-0.0.0.0:${PORT:-8505}}
+
+exec ./matterpoll-emoji
@@ -1,17 +1,77 @@
 package poll
 
 import (
-	"encoding/json"
 	"fmt"
-	"io/ioutil"
+	"net/url"
+	"time"
 )
 
 // Conf represents the login credentials of a mattermost user
 type Conf struct {
-	Host   string `json:"host"`
-	Listen string `json:"listen"`
-	Token  string `json:"token"`
-	User   User   `json:"user"`
+	// Instance is the default Mattermost server. Its settings are given at the top level of the config.
+	Instance
+	// Instances are further Mattermost servers
+	Instances []Instance `json:"instances"`
+	Listen    string     `json:"listen"`
+	URL       string     `json:"url"`
+	Store     StoreConf  `json:"store"`
+	Log       LogConf    `json:"log"`
+	// RefreshInterval is the interval the results in all open poll posts are refreshed. Zero disables the periodic refresh.
+	RefreshInterval Duration `json:"refresh_interval"`
+	// RefreshDelay is the time votes are collected before a poll post is refreshed
+	RefreshDelay Duration `json:"refresh_delay"`
+	// ShutdownTimeout is the time Matterpoll waits for requests and background work to finish when it is stopped
+	ShutdownTimeout Duration `json:"shutdown_timeout"`
+	// APITimeout is the time a request to the Mattermost API may take, so that one hanging server doesn't block the others
+	APITimeout Duration `json:"api_timeout"`
+
+	// file is the path of the config file, if one was read
+	file string
+	// sources maps the keys of values set by environment variables or flags to their source
+	sources map[string]string
+}
+
+const (
+	// DefaultRefreshDelay is used, if the config doesn't set refresh_delay
+	DefaultRefreshDelay = 2 * time.Second
+	// DefaultShutdownTimeout is used, if the config doesn't set shutdown_timeout
+	DefaultShutdownTimeout = 30 * time.Second
+	// DefaultAPITimeout is used, if the config doesn't set api_timeout
+	DefaultAPITimeout = 30 * time.Second
+)
+
+// Duration is a time.Duration which is written as a string like "1m30s" in the config
+type Duration time.Duration
+
+// UnmarshalText parses a duration like "1m30s"
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// MarshalText formats the duration like "1m30s"
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// CommandToken is the token of a slash command. Requests with the token use its team settings.
+type CommandToken struct {
+	Token string `json:"token"`
+	// TeamID restricts the token to requests from this team. Requests from any team are accepted, if it is empty.
+	TeamID string `json:"team_id"`
+	TeamSettings
+}
+
+// TeamSettings are the settings which may differ between the slash commands of several teams
+type TeamSettings struct {
+	// DefaultDuration ends polls without --duration or --until after this time. Polls without a deadline are kept open, if it is zero.
+	DefaultDuration Duration `json:"default_duration"`
+	// DisableAnonymous rejects anonymous polls
+	DisableAnonymous bool `json:"disable_anonymous"`
 }
 
 // User represents the login credentials of a mattermost user
@@ -20,40 +80,68 @@ type User struct {
 	Password string `json:"password"`
 }
 
-// LoadConf loads a configuration file located at path and parse it to a Conf struct
+// StoreConf configures where polls are saved
+type StoreConf struct {
+	Type string `json:"type"`
+	Path string `json:"path"`
+}
+
+// LogConf configures the log messages of Matterpoll
+type LogConf struct {
+	// Level is the lowest level which is logged: debug, info, warn or error
+	Level string `json:"level"`
+	// Format is logfmt or json
+	Format string `json:"format"`
+}
+
+// LoadConf loads a configuration file located at path and parse it to a Conf struct.
+// Use ConfLoader to override the values of the file with environment variables and flags.
 func LoadConf(path string) (*Conf, error) {
-	b, err := ioutil.ReadFile(path)
-	if err != nil {
-		return nil, err
-	}
-	var p Conf
-	if err := json.Unmarshal(b, &p); err != nil {
-		return nil, err
-	}
-	if err := p.validate(); err != nil {
-		return nil, err
-	}
-	return &p, nil
+	l := &ConfLoader{Path: path}
+	return l.Load()
 }
 
 func (c *Conf) validate() error {
-	if len(c.Host) == 0 {
-		return fmt.Errorf("Config `host` is missing")
-	}
 	if len(c.Listen) == 0 {
-		return fmt.Errorf("Config `listen` is missing")
+		return c.missing("listen")
+	}
+	if len(c.URL) != 0 {
+		u, err := url.Parse(c.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+			return c.invalid("url", "must be an absolute http or https URL")
+		}
+	}
+	if err := c.validateInstances(); err != nil {
+		return err
+	}
+	if c.RefreshInterval < 0 {
+		return c.invalid("refresh_interval", "must not be negative")
+	}
+	if c.RefreshDelay < 0 {
+		return c.invalid("refresh_delay", "must not be negative")
+	}
+	if c.ShutdownTimeout < 0 {
+		return c.invalid("shutdown_timeout", "must not be negative")
 	}
-	if len(c.Token) == 0 {
-		return fmt.Errorf("Config `token` is missing")
+	if c.APITimeout <= 0 {
+		return c.invalid("api_timeout", "must be positive")
 	}
-	if len(c.Token) != 26 {
-		return fmt.Errorf("Invalid token length. Check you config.json")
+	if _, err := ParseLevel(c.Log.Level); err != nil {
+		return c.invalid("log.level", "must be debug, info, warn or error")
 	}
-	if len(c.User.ID) == 0 {
-		return fmt.Errorf("Config `user.id` is missing")
+	switch c.Log.Format {
+	case "", LogFormatLogfmt, LogFormatJSON:
+	default:
+		return c.invalid("log.format", fmt.Sprintf("must be %q or %q", LogFormatLogfmt, LogFormatJSON))
 	}
-	if len(c.User.Password) == 0 {
-		return fmt.Errorf("Config `user.password` is missing")
+	switch c.Store.Type {
+	case "", StoreTypeMemory:
+	case StoreTypeFile:
+		if len(c.Store.Path) == 0 {
+			return c.missing("store.path")
+		}
+	default:
+		return c.invalid("store.type", fmt.Sprintf("must be %q or %q", StoreTypeMemory, StoreTypeFile))
 	}
 	return nil
 }
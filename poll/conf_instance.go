@@ -0,0 +1,183 @@
+package poll
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Instance is a Mattermost server served by Matterpoll
+type Instance struct {
+	// Name identifies the instance. Slash commands of the instance can be sent to CommandPath + "/" + Name.
+	Name  string `json:"name"`
+	Host  string `json:"host"`
+	Token string `json:"token"`
+	// Tokens are the tokens of further slash commands, e.g. of other teams
+	Tokens []CommandToken `json:"tokens"`
+	User   User           `json:"user"`
+	// AccessToken is a personal access token of the bot user. It is used instead of User.
+	AccessToken string `json:"access_token"`
+}
+
+// instanceNamePattern restricts instance names to characters which can be used in a URL path
+var instanceNamePattern = regexp.MustCompile(`^[a-z0-9_-]+$`)
+
+// reservedInstanceName can't be used as an instance name, because its path under CommandPath receives the button clicks of anonymous polls
+var reservedInstanceName = strings.TrimPrefix(VotePath, CommandPath+"/")
+
+// isSet reports whether any setting of the instance is given
+func (in *Instance) isSet() bool {
+	return len(in.Name) != 0 || len(in.Host) != 0 || len(in.Token) != 0 || len(in.Tokens) != 0 ||
+		len(in.User.ID) != 0 || len(in.User.Password) != 0 || len(in.AccessToken) != 0
+}
+
+// instances returns all configured Mattermost servers. The default instance is only included, if it is configured.
+func (c *Conf) instances() []Instance {
+	var instances []Instance
+	if c.Instance.isSet() || len(c.Instances) == 0 {
+		instances = append(instances, c.Instance)
+	}
+	return append(instances, c.Instances...)
+}
+
+// instance returns the Mattermost server called name
+func (c *Conf) instance(name string) (*Instance, bool) {
+	for _, in := range c.instances() {
+		if in.Name == name {
+			in := in
+			return &in, true
+		}
+	}
+	return nil, false
+}
+
+// commandTokens returns all slash command tokens of the instance. The token of Instance.Token has the default settings.
+func (in *Instance) commandTokens() []CommandToken {
+	var tokens []CommandToken
+	if len(in.Token) != 0 {
+		tokens = append(tokens, CommandToken{Token: in.Token})
+	}
+	return append(tokens, in.Tokens...)
+}
+
+// commandToken returns the instance and the slash command token which match token and teamID.
+// If name is not empty, only the instance called name is searched.
+// All tokens are compared in constant time, so that the comparison doesn't leak how much of a token matched.
+func (c *Conf) commandToken(name string, token string, teamID string) (*Instance, *CommandToken, bool) {
+	var instance *Instance
+	var match *CommandToken
+	for _, in := range c.instances() {
+		in := in
+		for _, t := range in.commandTokens() {
+			t := t
+			equal := subtle.ConstantTimeCompare([]byte(t.Token), []byte(token)) == 1
+			if equal && match == nil && (len(name) == 0 || in.Name == name) && (len(t.TeamID) == 0 || t.TeamID == teamID) {
+				instance, match = &in, &t
+			}
+		}
+	}
+	return instance, match, match != nil
+}
+
+// validateInstances checks the settings of all Mattermost servers
+func (c *Conf) validateInstances() error {
+	if len(c.Instances) == 0 && len(c.Host) == 0 {
+		return c.missing("host")
+	}
+	names := make(map[string]bool)
+	tokens := make(map[string]bool)
+	if c.Instance.isSet() {
+		if len(c.Name) != 0 {
+			if err := c.validateInstanceName(c.Name, "name"); err != nil {
+				return err
+			}
+		}
+		if err := c.validateInstance(&c.Instance, "", tokens); err != nil {
+			return err
+		}
+		names[c.Name] = true
+	}
+	for i := range c.Instances {
+		in := &c.Instances[i]
+		prefix := fmt.Sprintf("instances[%d].", i)
+		if len(in.Name) == 0 {
+			return c.missing(prefix + "name")
+		}
+		if err := c.validateInstanceName(in.Name, prefix+"name"); err != nil {
+			return err
+		}
+		if names[in.Name] {
+			return c.invalid(prefix+"name", "is used by more than one instance")
+		}
+		names[in.Name] = true
+		if err := c.validateInstance(in, prefix, tokens); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateInstanceName checks that name can be used in the path of the slash commands. key is the setting of the name.
+func (c *Conf) validateInstanceName(name string, key string) error {
+	if !instanceNamePattern.MatchString(name) {
+		return c.invalid(key, "must only contain lower case letters, digits, - and _")
+	}
+	if name == reservedInstanceName {
+		return c.invalid(key, "is reserved for the buttons of anonymous polls")
+	}
+	return nil
+}
+
+// validateInstance checks the settings of in. prefix is prepended to the keys in errors.
+// tokens are the slash command tokens of the instances checked so far. Every token may only be used once.
+func (c *Conf) validateInstance(in *Instance, prefix string, tokens map[string]bool) error {
+	if len(in.Host) == 0 {
+		return c.missing(prefix + "host")
+	}
+	if len(in.Token) == 0 && len(in.Tokens) == 0 {
+		return c.missing(prefix + "token")
+	}
+	if len(in.Token) != 0 {
+		if len(in.Token) != 26 {
+			return c.invalid(prefix+"token", "has an invalid length. Copy it from the slash command in Mattermost")
+		}
+		if tokens[in.Token] {
+			return c.invalid(prefix+"token", "is configured more than once")
+		}
+		tokens[in.Token] = true
+	}
+	for i, t := range in.Tokens {
+		key := fmt.Sprintf("%stokens[%d]", prefix, i)
+		if len(t.Token) != 26 {
+			return c.invalid(key+".token", "has an invalid length. Copy it from the slash command in Mattermost")
+		}
+		if tokens[t.Token] {
+			return c.invalid(key+".token", "is configured more than once")
+		}
+		tokens[t.Token] = true
+		if len(t.TeamID) != 0 && len(t.TeamID) != 26 {
+			return c.invalid(key+".team_id", "has an invalid length")
+		}
+		if t.DefaultDuration < 0 {
+			return c.invalid(key+".default_duration", "must not be negative")
+		}
+	}
+	hasUser := len(in.User.ID) != 0 || len(in.User.Password) != 0
+	if hasUser && len(in.AccessToken) != 0 {
+		return fmt.Errorf("Config `%suser` from %s and `%saccess_token` from %s are both set. Use only one of them",
+			prefix, c.source(prefix+"user.id"), prefix, c.source(prefix+"access_token"))
+	}
+	if len(in.AccessToken) == 0 {
+		if !hasUser {
+			return fmt.Errorf("Config `%suser` or `%saccess_token` is missing. Set one of them in the config file, with environment variables or with flags", prefix, prefix)
+		}
+		if len(in.User.ID) == 0 {
+			return c.missing(prefix + "user.id")
+		}
+		if len(in.User.Password) == 0 {
+			return c.missing(prefix + "user.password")
+		}
+	}
+	return nil
+}
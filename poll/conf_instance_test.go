@@ -0,0 +1,71 @@
+package poll_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/kaakaa/matterpoll-emoji/poll"
+	"github.com/mattermost/mattermost-server/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommandInstances(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	production, staging := newFakeMattermost(), newFakeMattermost()
+	defer production.Close()
+	defer staging.Close()
+	c, err := getConfig("sample_conf_instances.json")
+	require.Nil(err)
+	c.Host = production.URL
+	c.Instances[0].Host = staging.URL
+	c.Instances[0].AccessToken = staging.CreateAccessToken()
+	ps, err := poll.NewServer(c)
+	require.Nil(err)
+	ps.Start()
+	defer ps.Stop()
+	require.True(waitFor(func() bool { return production.Connected() == 1 && staging.Connected() == 1 }))
+
+	creator := model.NewId()
+	send := func(url, token, text string) string {
+		payload := fmt.Sprintf("token=%s&user_id=%s&channel_id=%s&text=%s", token, creator, model.NewId(), text)
+		response, _ := sendHttpRequestTo(require, ps, url, payload)
+		return response.Text
+	}
+
+	// The instance is chosen by the token
+	send("http://localhost:8505/poll", c.Instances[0].Token, `"Staging?" :pizza:`)
+	require.Len(staging.Posts(), 1)
+	assert.Len(production.Posts(), 0)
+	send("http://localhost:8505/poll", c.Token, `"Production?" :pizza:`)
+	assert.Len(staging.Posts(), 1)
+	require.Len(production.Posts(), 1)
+
+	// The instance is chosen by the path
+	assert.Equal(poll.ErrorTokenMissmatch, send("http://localhost:8505/poll/staging", c.Token, `"Staging?" :pizza:`))
+	assert.Equal(poll.ErrorTokenMissmatch, send("http://localhost:8505/poll/unknown", c.Instances[0].Token, `"Staging?" :pizza:`))
+	send("http://localhost:8505/poll/staging", c.Instances[0].Token, `"Staging?" :pizza:`)
+	assert.Len(staging.Posts(), 2)
+
+	polls, err := ps.Store.List()
+	require.Nil(err)
+	require.Len(polls, 3)
+	assert.Equal("staging", polls[0].Instance)
+	assert.Equal("", polls[1].Instance)
+	assert.True(waitFor(func() bool { return len(staging.Reactions(polls[0].PostID)) == 1 }))
+	assert.True(waitFor(func() bool { return len(production.Reactions(polls[1].PostID)) == 1 }))
+
+	// Polls can only be ended through their instance
+	assert.Equal(poll.ErrorPollNotFound, send("http://localhost:8505/poll", c.Instances[0].Token, "end "+polls[1].ID))
+	assert.Equal(poll.ResponseTextEnded, send("http://localhost:8505/poll", c.Token, "end "+polls[1].ID))
+	require.Len(production.Posts(), 2)
+	assert.Equal(polls[1].PostID, production.Posts()[1].RootId)
+
+	// A failing instance doesn't affect the others
+	staging.Close()
+	assert.Equal(poll.ErrorPostFailed, send("http://localhost:8505/poll", c.Instances[0].Token, `"Staging?" :pizza:`))
+	send("http://localhost:8505/poll", c.Token, `"Production?" :pizza:`)
+	assert.Len(production.Posts(), 3)
+}
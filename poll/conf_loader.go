@@ -0,0 +1,270 @@
+package poll
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml"
+	"gopkg.in/yaml.v2"
+)
+
+// EnvPrefix is the prefix of the environment variables which override the config file, e.g. MATTERPOLL_HOST
+const EnvPrefix = "MATTERPOLL_"
+
+// The formats of config files
+const (
+	ConfFormatJSON = "json"
+	ConfFormatYAML = "yaml"
+	ConfFormatTOML = "toml"
+)
+
+// ConfLoader builds a Conf from several sources. Later sources override earlier ones:
+// the defaults, the config file, the environment variables and the command line flags.
+type ConfLoader struct {
+	// Path is the config file. No file is read, if Path is empty.
+	Path string
+	// Optional makes a missing config file not an error
+	Optional bool
+	// Format is the format of the config file. If it is empty, the format is detected from the file extension.
+	Format string
+	// LookupEnv returns the value of an environment variable, usually os.LookupEnv. The environment is ignored, if it is nil.
+	LookupEnv func(key string) (string, bool)
+
+	flags *flag.FlagSet
+}
+
+// setting is a value of Conf which can be set by an environment variable or a flag
+type setting struct {
+	// key is the name of the value in the config file. Nested values are separated by a dot.
+	key string
+	// secret values are never logged
+	secret bool
+	get    func(c *Conf) string
+	set    func(c *Conf, value string) error
+}
+
+var settings = []setting{
+	stringSetting("host", false, func(c *Conf) *string { return &c.Host }),
+	stringSetting("listen", false, func(c *Conf) *string { return &c.Listen }),
+	stringSetting("url", false, func(c *Conf) *string { return &c.URL }),
+	stringSetting("token", true, func(c *Conf) *string { return &c.Token }),
+	stringSetting("user.id", false, func(c *Conf) *string { return &c.User.ID }),
+	stringSetting("user.password", true, func(c *Conf) *string { return &c.User.Password }),
+	stringSetting("access_token", true, func(c *Conf) *string { return &c.AccessToken }),
+	stringSetting("store.type", false, func(c *Conf) *string { return &c.Store.Type }),
+	stringSetting("store.path", false, func(c *Conf) *string { return &c.Store.Path }),
+	stringSetting("log.level", false, func(c *Conf) *string { return &c.Log.Level }),
+	stringSetting("log.format", false, func(c *Conf) *string { return &c.Log.Format }),
+	durationSetting("refresh_interval", func(c *Conf) *Duration { return &c.RefreshInterval }),
+	durationSetting("refresh_delay", func(c *Conf) *Duration { return &c.RefreshDelay }),
+	durationSetting("shutdown_timeout", func(c *Conf) *Duration { return &c.ShutdownTimeout }),
+	durationSetting("api_timeout", func(c *Conf) *Duration { return &c.APITimeout }),
+}
+
+func stringSetting(key string, secret bool, field func(c *Conf) *string) setting {
+	return setting{
+		key:    key,
+		secret: secret,
+		get:    func(c *Conf) string { return *field(c) },
+		set: func(c *Conf, value string) error {
+			*field(c) = value
+			return nil
+		},
+	}
+}
+
+func durationSetting(key string, field func(c *Conf) *Duration) setting {
+	return setting{
+		key: key,
+		get: func(c *Conf) string { return time.Duration(*field(c)).String() },
+		set: func(c *Conf, value string) error {
+			return field(c).UnmarshalText([]byte(value))
+		},
+	}
+}
+
+// envName returns the environment variable of the config value key, e.g. MATTERPOLL_USER_ID for user.id
+func envName(key string) string {
+	return EnvPrefix + strings.ToUpper(strings.Replace(key, ".", "_", -1))
+}
+
+// flagName returns the command line flag of the config value key, e.g. user-id for user.id
+func flagName(key string) string {
+	return strings.NewReplacer(".", "-", "_", "-").Replace(key)
+}
+
+// RegisterFlags adds a flag for every config value to fs. Only flags which are set on the command line override other sources.
+func (l *ConfLoader) RegisterFlags(fs *flag.FlagSet) {
+	for _, s := range settings {
+		fs.String(flagName(s.key), "", fmt.Sprintf("overrides %s of the config file", s.key))
+	}
+	l.flags = fs
+}
+
+// Load reads all sources and validates the merged config
+func (l *ConfLoader) Load() (*Conf, error) {
+	c := &Conf{
+		RefreshDelay:    Duration(DefaultRefreshDelay),
+		ShutdownTimeout: Duration(DefaultShutdownTimeout),
+		APITimeout:      Duration(DefaultAPITimeout),
+		Log:             LogConf{Level: LevelInfo.String(), Format: LogFormatLogfmt},
+		sources:         make(map[string]string),
+	}
+	if len(l.Path) != 0 {
+		if err := l.readFile(c); err != nil {
+			return nil, err
+		}
+	}
+	if l.LookupEnv != nil {
+		for _, s := range settings {
+			name := envName(s.key)
+			if value, ok := l.LookupEnv(name); ok && len(value) != 0 {
+				if err := c.apply(s, value, "environment variable "+name); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	if l.flags != nil {
+		var err error
+		l.flags.Visit(func(f *flag.Flag) {
+			for _, s := range settings {
+				if err == nil && flagName(s.key) == f.Name {
+					err = c.apply(s, f.Value.String(), "flag -"+f.Name)
+				}
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := c.validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (l *ConfLoader) readFile(c *Conf) error {
+	b, err := ioutil.ReadFile(l.Path)
+	if os.IsNotExist(err) && l.Optional {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	format := l.Format
+	if len(format) == 0 {
+		format = confFormat(l.Path)
+	}
+	if err := decodeConf(format, b, c); err != nil {
+		return fmt.Errorf("Failed to parse config file %s: %v", l.Path, err)
+	}
+	c.file = l.Path
+	return nil
+}
+
+// confFormat detects the format of the config file at path from its extension. Files with an unknown extension are read as JSON.
+func confFormat(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return ConfFormatYAML
+	case ".toml":
+		return ConfFormatTOML
+	default:
+		return ConfFormatJSON
+	}
+}
+
+// decodeConf parses b in format into c. YAML and TOML are converted to JSON first,
+// so that all formats use the same keys and values are parsed the same way.
+func decodeConf(format string, b []byte, c *Conf) error {
+	var m map[string]interface{}
+	switch format {
+	case ConfFormatJSON:
+		return json.Unmarshal(b, c)
+	case ConfFormatYAML:
+		var v map[interface{}]interface{}
+		if err := yaml.Unmarshal(b, &v); err != nil {
+			return err
+		}
+		m = stringKeys(v)
+	case ConfFormatTOML:
+		t, err := toml.LoadBytes(b)
+		if err != nil {
+			return err
+		}
+		m = t.ToMap()
+	default:
+		return fmt.Errorf("unknown format %q. Use %q, %q or %q", format, ConfFormatJSON, ConfFormatYAML, ConfFormatTOML)
+	}
+	j, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(j, c)
+}
+
+// stringKeys converts the maps decoded by yaml to maps with string keys, which can be encoded as JSON
+func stringKeys(v map[interface{}]interface{}) map[string]interface{} {
+	m := make(map[string]interface{}, len(v))
+	for key, value := range v {
+		m[fmt.Sprint(key)] = stringKeysValue(value)
+	}
+	return m
+}
+
+func stringKeysValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		return stringKeys(v)
+	case []interface{}:
+		values := make([]interface{}, len(v))
+		for i, item := range v {
+			values[i] = stringKeysValue(item)
+		}
+		return values
+	default:
+		return value
+	}
+}
+
+// apply sets the value of s and remembers where it came from
+func (c *Conf) apply(s setting, value string, source string) error {
+	if err := s.set(c, value); err != nil {
+		return fmt.Errorf("Config `%s` from %s is invalid: %v", s.key, source, err)
+	}
+	c.sources[s.key] = source
+	return nil
+}
+
+// source describes where the value of key came from
+func (c *Conf) source(key string) string {
+	if s, ok := c.sources[key]; ok {
+		return s
+	}
+	if len(c.file) != 0 {
+		return "config file " + c.file
+	}
+	return "defaults"
+}
+
+// invalid returns an error for the value of key, which names the source of the value
+func (c *Conf) invalid(key string, reason string) error {
+	return fmt.Errorf("Config `%s` from %s %s", key, c.source(key), reason)
+}
+
+// missing returns an error for the missing value of key, which names all ways to set it
+func (c *Conf) missing(key string) error {
+	for _, s := range settings {
+		if s.key == key {
+			return fmt.Errorf("Config `%s` is missing. Set it in the config file, with %s or with -%s", key, envName(key), flagName(key))
+		}
+	}
+	return fmt.Errorf("Config `%s` is missing. Set it in the config file", key)
+}
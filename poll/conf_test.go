@@ -1,9 +1,12 @@
 package poll_test
 
 import (
+	"flag"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/kaakaa/matterpoll-emoji/poll"
 	"github.com/stretchr/testify/assert"
@@ -25,6 +28,9 @@ func TestReadConf(t *testing.T) {
 	assert.Equal(c.Token, "9jrxak1ykxrmnaed9cps9i4cim")
 	assert.Equal(c.User.ID, "bot")
 	assert.Equal(c.User.Password, "botbot")
+	assert.Equal(poll.Duration(0), c.RefreshInterval)
+	assert.Equal(poll.Duration(poll.DefaultRefreshDelay), c.RefreshDelay)
+	assert.Equal(poll.Duration(poll.DefaultShutdownTimeout), c.ShutdownTimeout)
 }
 
 func TestValidate(t *testing.T) {
@@ -43,23 +49,135 @@ func TestValidate(t *testing.T) {
 		{"sample_conf_error_no_user.json", true},
 		{"sample_conf_error_no_user_id.json", true},
 		{"sample_conf_error_no_user_password.json", true},
+		{"sample_conf_store_file.json", false},
+		{"sample_conf_error_no_store_path.json", true},
+		{"sample_conf_error_unknown_store_type.json", true},
+		{"sample_conf_error_wrong_url.json", true},
+		{"sample_conf_error_wrong_refresh_interval.json", true},
+		{"sample_conf_error_negative_refresh_delay.json", true},
+		{"sample_conf_access_token.json", false},
+		{"sample_conf_error_user_and_access_token.json", true},
+		{"sample_conf_error_negative_shutdown_timeout.json", true},
+		{"sample_conf_error_wrong_api_timeout.json", true},
+		{"sample_conf_tokens.json", false},
+		{"sample_conf_error_duplicate_tokens.json", true},
+		{"sample_conf_error_wrong_tokens_length.json", true},
+		{"sample_conf_instances.json", false},
+		{"sample_conf_instances_only.json", false},
+		{"sample_conf_error_instance_duplicate_token.json", true},
+		{"sample_conf_error_instance_wrong_name.json", true},
+		{"sample_conf_error_instance_reserved_name.json", true},
+		{"sample_conf_error_wrong_name.json", true},
+		{"sample_conf_error_reserved_name.json", true},
+		{"sample_conf_error_instance_no_name.json", true},
+		{"sample_conf_error_instance_no_host.json", true},
+		{"sample_conf_error_wrong_log_level.json", true},
 	}
 	for _, test := range tests {
-		p, err := getTestFilePath(test.Filename)
-		assert.Nil(err)
-		require.NotNil(p)
-
-		c, err := poll.LoadConf(p)
-		if test.ShouldError {
-			assert.NotNil(err)
-			assert.Nil(c)
-		} else {
+		for _, ext := range []string{".json", ".yaml", ".toml"} {
+			filename := strings.TrimSuffix(test.Filename, ".json") + ext
+			p, err := getTestFilePath(filename)
 			assert.Nil(err)
-			assert.NotNil(c)
+			require.NotNil(p)
+
+			c, err := poll.LoadConf(p)
+			if test.ShouldError {
+				assert.NotNil(err, filename)
+				assert.Nil(c, filename)
+			} else {
+				assert.Nil(err, filename)
+				assert.NotNil(c, filename)
+			}
 		}
 	}
 }
 
+func TestReadConfFormats(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	for _, filename := range []string{"sample_conf_store_file.json", "sample_conf_store_file.yaml", "sample_conf_store_file.toml"} {
+		p, err := getTestFilePath(filename)
+		require.Nil(err)
+		c, err := poll.LoadConf(p)
+		require.Nil(err, filename)
+		require.NotNil(c)
+
+		assert.Equal("http://localhost:8065", c.Host, filename)
+		assert.Equal(":8505", c.Listen, filename)
+		assert.Equal("9jrxak1ykxrmnaed9cps9i4cim", c.Token, filename)
+		assert.Equal("bot", c.User.ID, filename)
+		assert.Equal("botbot", c.User.Password, filename)
+		assert.Equal(poll.StoreTypeFile, c.Store.Type, filename)
+		assert.Equal("polls.json", c.Store.Path, filename)
+		assert.Equal(poll.Duration(poll.DefaultRefreshDelay), c.RefreshDelay, filename)
+	}
+}
+
+func TestReadConfTokens(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	for _, filename := range []string{"sample_conf_tokens.json", "sample_conf_tokens.yaml", "sample_conf_tokens.toml"} {
+		p, err := getTestFilePath(filename)
+		require.Nil(err)
+		c, err := poll.LoadConf(p)
+		require.Nil(err, filename)
+		require.NotNil(c)
+
+		require.Len(c.Tokens, 2, filename)
+		assert.Equal("o1mhqkbzi3r85gxpk4cq1ubr7y", c.Tokens[0].Token, filename)
+		assert.Equal("iazrd9p1xpn6fqrgsahjm4u6ae", c.Tokens[0].TeamID, filename)
+		assert.Equal(poll.Duration(24*time.Hour), c.Tokens[0].DefaultDuration, filename)
+		assert.True(c.Tokens[0].DisableAnonymous, filename)
+		assert.Equal(poll.CommandToken{Token: "cq46dwn8ufd3tyr3zhdzz5mqme"}, c.Tokens[1], filename)
+	}
+}
+
+func TestReadConfInstances(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	staging := poll.Instance{
+		Name:        "staging",
+		Host:        "http://staging.localhost:8065",
+		Token:       "cq46dwn8ufd3tyr3zhdzz5mqme",
+		AccessToken: "ckoc4jfsb3bx3ec9o1cc8gz6ah",
+	}
+	for _, filename := range []string{"sample_conf_instances_only.json", "sample_conf_instances_only.yaml", "sample_conf_instances_only.toml"} {
+		p, err := getTestFilePath(filename)
+		require.Nil(err)
+		c, err := poll.LoadConf(p)
+		require.Nil(err, filename)
+		require.NotNil(c)
+
+		assert.Equal(poll.Instance{}, c.Instance, filename)
+		require.Len(c.Instances, 2, filename)
+		assert.Equal("production", c.Instances[0].Name, filename)
+		assert.Equal(poll.User{ID: "bot", Password: "botbot"}, c.Instances[0].User, filename)
+		assert.Equal(staging, c.Instances[1], filename)
+	}
+}
+
+func TestReadConfExplicitFormat(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	p, err := getTestFilePath("sample_conf.yaml")
+	require.Nil(err)
+
+	c, err := (&poll.ConfLoader{Path: p, Format: poll.ConfFormatYAML}).Load()
+	assert.Nil(err)
+	assert.NotNil(c)
+
+	c, err = (&poll.ConfLoader{Path: p, Format: poll.ConfFormatJSON}).Load()
+	assert.NotNil(err)
+	assert.Nil(c)
+
+	c, err = (&poll.ConfLoader{Path: p, Format: "ini"}).Load()
+	assert.NotNil(err)
+	assert.Nil(c)
+}
+
 func TestReadConfNotExistsError(t *testing.T) {
 	assert := assert.New(t)
 	require := require.New(t)
@@ -79,3 +197,94 @@ func getTestFilePath(path string) (string, error) {
 	}
 	return filepath.Join(d, "..", "testdata", path), nil
 }
+
+func TestConfLoaderLayers(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	p, err := getTestFilePath("sample_conf.json")
+	require.Nil(err)
+
+	env := map[string]string{
+		"MATTERPOLL_HOST":          "http://env.example.com",
+		"MATTERPOLL_LISTEN":        "0.0.0.0:8080",
+		"MATTERPOLL_REFRESH_DELAY": "5s",
+		"MATTERPOLL_URL":           "",
+	}
+	l := &poll.ConfLoader{Path: p, LookupEnv: lookupEnv(env)}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	l.RegisterFlags(fs)
+	require.Nil(fs.Parse([]string{"-listen", "127.0.0.1:9090", "-user-id", "flagbot"}))
+
+	c, err := l.Load()
+	require.Nil(err)
+	require.NotNil(c)
+	// From the config file
+	assert.Equal("9jrxak1ykxrmnaed9cps9i4cim", c.Token)
+	assert.Equal("botbot", c.User.Password)
+	// From the environment
+	assert.Equal("http://env.example.com", c.Host)
+	assert.Equal(poll.Duration(5*time.Second), c.RefreshDelay)
+	// From the flags, which override the environment
+	assert.Equal("127.0.0.1:9090", c.Listen)
+	assert.Equal("flagbot", c.User.ID)
+}
+
+func TestConfLoaderWithoutFile(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	env := map[string]string{
+		"MATTERPOLL_HOST":         "http://localhost:8065",
+		"MATTERPOLL_LISTEN":       ":8505",
+		"MATTERPOLL_TOKEN":        "9jrxak1ykxrmnaed9cps9i4cim",
+		"MATTERPOLL_ACCESS_TOKEN": "ckoc4jfsb3bx3ec9o1cc8gz6ah",
+	}
+	l := &poll.ConfLoader{Path: "not_exists.json", Optional: true, LookupEnv: lookupEnv(env)}
+	c, err := l.Load()
+	require.Nil(err)
+	require.NotNil(c)
+	assert.Equal("ckoc4jfsb3bx3ec9o1cc8gz6ah", c.AccessToken)
+	assert.Equal(poll.Duration(poll.DefaultRefreshDelay), c.RefreshDelay)
+
+	l.Optional = false
+	c, err = l.Load()
+	assert.NotNil(err)
+	assert.Nil(c)
+}
+
+func TestConfLoaderErrorsNameSource(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	p, err := getTestFilePath("sample_conf.json")
+	require.Nil(err)
+
+	for _, test := range []struct {
+		Env   map[string]string
+		Args  []string
+		Error string
+	}{
+		{map[string]string{"MATTERPOLL_REFRESH_DELAY": "soon"}, nil, "environment variable MATTERPOLL_REFRESH_DELAY"},
+		{map[string]string{"MATTERPOLL_URL": "example.com"}, nil, "environment variable MATTERPOLL_URL"},
+		{nil, []string{"-token", "short"}, "flag -token"},
+		{nil, []string{"-store-type", "redis"}, "flag -store-type"},
+		{nil, []string{"-access-token", "ckoc4jfsb3bx3ec9o1cc8gz6ah"}, "config file " + p},
+		{nil, []string{"-host", ""}, "MATTERPOLL_HOST"},
+	} {
+		l := &poll.ConfLoader{Path: p, LookupEnv: lookupEnv(test.Env)}
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		l.RegisterFlags(fs)
+		require.Nil(fs.Parse(test.Args))
+
+		c, err := l.Load()
+		assert.Nil(c)
+		require.NotNil(err)
+		assert.Contains(err.Error(), test.Error)
+	}
+}
+
+func lookupEnv(env map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		value, ok := env[key]
+		return value, ok
+	}
+}
@@ -0,0 +1,473 @@
+package poll_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/kaakaa/matterpoll-emoji/poll"
+	"github.com/mattermost/mattermost-server/model"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeMattermost is a minimal in-memory implementation of the parts of the Mattermost APIv4 used by Matterpoll
+type fakeMattermost struct {
+	*httptest.Server
+	BotID string
+
+	mu        sync.Mutex
+	posts     map[string]*model.Post
+	order     []string
+	reactions map[string][]*model.Reaction
+	patches   int
+	sockets   []*fakeSocket
+	tokens    map[string]bool
+	logins    int
+	// emojis are the custom emojis by name
+	emojis       map[string]bool
+	emojiLookups int
+	// reactionFailures are the status codes returned instead of saving reactions by emoji name
+	reactionFailures map[string][]int
+	// directChannels are the members of the direct channels by channel id
+	directChannels map[string][]string
+	// reactionDelay slows down saving reactions
+	reactionDelay time.Duration
+	// postDelay slows down creating posts
+	postDelay time.Duration
+	// authDelay slows down logins and looking up the bot user
+	authDelay time.Duration
+	// reactionListDelays slow down reading the reactions of a post, one entry per request
+	reactionListDelays []time.Duration
+}
+
+type fakeSocket struct {
+	mu   sync.Mutex
+	conn *websocket.Conn
+}
+
+func newFakeMattermost() *fakeMattermost {
+	f := &fakeMattermost{
+		BotID:     model.NewId(),
+		posts:     make(map[string]*model.Post),
+		reactions: make(map[string][]*model.Reaction),
+		tokens:    make(map[string]bool),
+		emojis:    make(map[string]bool),
+
+		reactionFailures: make(map[string][]int),
+		directChannels:   make(map[string][]string),
+	}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serve))
+	return f
+}
+
+// newTestServer creates a Matterpoll server with sample_conf.json, which talks to a new fake Mattermost server.
+// The fake server has to be closed by the caller.
+func newTestServer(require *require.Assertions) (*poll.Server, *fakeMattermost) {
+	mm := newFakeMattermost()
+	c, err := getConfig("sample_conf.json")
+	require.Nil(err)
+	c.Host = mm.URL
+	ps, err := poll.NewServer(c)
+	require.Nil(err)
+	return ps, mm
+}
+
+func (f *fakeMattermost) serve(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, model.API_URL_SUFFIX)
+	if path != "/users/login" && path != "/websocket" && path != "/system/ping" && !f.authorized(r.Header.Get(model.HEADER_AUTH)) {
+		writeJSON(w, http.StatusUnauthorized, model.NewAppError("fakeMattermost", "api.context.session_expired.app_error", nil, path, http.StatusUnauthorized))
+		return
+	}
+	if path == "/users/login" || path == "/users/me" {
+		f.mu.Lock()
+		delay := f.authDelay
+		f.mu.Unlock()
+		time.Sleep(delay)
+	}
+	switch {
+	case r.Method == http.MethodPost && path == "/users/login":
+		token := model.NewId()
+		f.mu.Lock()
+		f.tokens[token] = true
+		f.logins++
+		f.mu.Unlock()
+		w.Header().Set(model.HEADER_TOKEN, token)
+		writeJSON(w, http.StatusOK, &model.User{Id: f.BotID, Username: "bot"})
+	case r.Method == http.MethodGet && path == "/system/ping":
+		writeJSON(w, http.StatusOK, map[string]string{"status": "OK"})
+	case r.Method == http.MethodGet && path == "/users/me":
+		writeJSON(w, http.StatusOK, &model.User{Id: f.BotID, Username: "bot"})
+	case r.Method == http.MethodPost && path == "/posts":
+		f.mu.Lock()
+		delay := f.postDelay
+		f.mu.Unlock()
+		time.Sleep(delay)
+		post := model.PostFromJson(r.Body)
+		post.Id = model.NewId()
+		post.UserId = f.BotID
+		post.CreateAt = model.GetMillis()
+		f.mu.Lock()
+		f.posts[post.Id] = post
+		f.order = append(f.order, post.Id)
+		f.mu.Unlock()
+		writeJSON(w, http.StatusCreated, post)
+	case r.Method == http.MethodPut && strings.HasPrefix(path, "/posts/") && strings.HasSuffix(path, "/patch"):
+		postID := strings.TrimSuffix(strings.TrimPrefix(path, "/posts/"), "/patch")
+		patch := model.PostPatchFromJson(r.Body)
+		f.mu.Lock()
+		post, ok := f.posts[postID]
+		if ok {
+			post.Patch(patch)
+			f.patches++
+		}
+		f.mu.Unlock()
+		if !ok {
+			writeJSON(w, http.StatusNotFound, model.NewAppError("fakeMattermost", "api.post.get.app_error", nil, postID, http.StatusNotFound))
+			return
+		}
+		writeJSON(w, http.StatusOK, post)
+	case r.Method == http.MethodDelete && strings.HasPrefix(path, "/posts/"):
+		postID := strings.TrimPrefix(path, "/posts/")
+		f.mu.Lock()
+		_, ok := f.posts[postID]
+		delete(f.posts, postID)
+		for i, id := range f.order {
+			if id == postID {
+				f.order = append(f.order[:i], f.order[i+1:]...)
+				break
+			}
+		}
+		f.mu.Unlock()
+		if !ok {
+			writeJSON(w, http.StatusNotFound, model.NewAppError("fakeMattermost", "api.post.get.app_error", nil, postID, http.StatusNotFound))
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "OK"})
+	case r.Method == http.MethodGet && strings.HasPrefix(path, "/posts/") && strings.HasSuffix(path, "/reactions"):
+		postID := strings.TrimSuffix(strings.TrimPrefix(path, "/posts/"), "/reactions")
+		f.mu.Lock()
+		var delay time.Duration
+		if len(f.reactionListDelays) != 0 {
+			delay = f.reactionListDelays[0]
+			f.reactionListDelays = f.reactionListDelays[1:]
+		}
+		f.mu.Unlock()
+		time.Sleep(delay)
+		f.mu.Lock()
+		reactions := append([]*model.Reaction{}, f.reactions[postID]...)
+		f.mu.Unlock()
+		writeJSON(w, http.StatusOK, reactions)
+	case r.Method == http.MethodPost && path == "/reactions":
+		f.mu.Lock()
+		delay := f.reactionDelay
+		f.mu.Unlock()
+		time.Sleep(delay)
+		reaction := model.ReactionFromJson(r.Body)
+		f.mu.Lock()
+		var status int
+		if failures := f.reactionFailures[reaction.EmojiName]; len(failures) != 0 {
+			status = failures[0]
+			if len(failures) > 1 {
+				f.reactionFailures[reaction.EmojiName] = failures[1:]
+			}
+		}
+		f.mu.Unlock()
+		if status != 0 {
+			writeJSON(w, status, model.NewAppError("fakeMattermost", "api.reaction.save_reaction.app_error", nil, reaction.EmojiName, status))
+			return
+		}
+		f.saveReaction(reaction)
+		writeJSON(w, http.StatusOK, reaction)
+	case r.Method == http.MethodDelete && strings.HasPrefix(path, "/users/") && strings.Contains(path, "/reactions/"):
+		// /users/{user_id}/posts/{post_id}/reactions/{emoji_name}
+		parts := strings.Split(path, "/")
+		if len(parts) != 7 {
+			writeJSON(w, http.StatusBadRequest, model.NewAppError("fakeMattermost", "api.context.invalid_url.app_error", nil, path, http.StatusBadRequest))
+			return
+		}
+		f.deleteReaction(parts[4], parts[2], parts[6])
+		writeJSON(w, http.StatusOK, map[string]string{"status": "OK"})
+	case r.Method == http.MethodGet && strings.HasPrefix(path, "/emoji/name/"):
+		name := strings.TrimPrefix(path, "/emoji/name/")
+		f.mu.Lock()
+		f.emojiLookups++
+		exists := f.emojis[name]
+		f.mu.Unlock()
+		if !exists {
+			writeJSON(w, http.StatusNotFound, model.NewAppError("fakeMattermost", "app.emoji.get_by_name.no_result", nil, name, http.StatusNotFound))
+			return
+		}
+		writeJSON(w, http.StatusOK, &model.Emoji{Id: model.NewId(), Name: name})
+	case r.Method == http.MethodPost && path == "/channels/direct":
+		members := model.ArrayFromJson(r.Body)
+		channel := &model.Channel{Id: model.NewId(), Type: model.CHANNEL_DIRECT}
+		f.mu.Lock()
+		f.directChannels[channel.Id] = members
+		f.mu.Unlock()
+		writeJSON(w, http.StatusCreated, channel)
+	case path == "/websocket":
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		socket := &fakeSocket{conn: conn}
+		f.mu.Lock()
+		f.sockets = append(f.sockets, socket)
+		f.mu.Unlock()
+		go func() {
+			// Answer the authentication challenge and discard everything else sent by the client
+			for {
+				_, data, err := conn.ReadMessage()
+				if err != nil {
+					return
+				}
+				req := model.WebSocketRequestFromJson(strings.NewReader(string(data)))
+				if req == nil || req.Action != model.WEBSOCKET_AUTHENTICATION_CHALLENGE {
+					continue
+				}
+				status := model.STATUS_OK
+				if token, _ := req.Data["token"].(string); !f.authorized(token) {
+					status = model.STATUS_FAIL
+				}
+				socket.mu.Lock()
+				conn.WriteMessage(websocket.TextMessage, []byte((&model.WebSocketResponse{Status: status, SeqReply: req.Seq}).ToJson()))
+				socket.mu.Unlock()
+			}
+		}()
+	default:
+		writeJSON(w, http.StatusNotFound, model.NewAppError("fakeMattermost", "api.context.404.app_error", nil, path, http.StatusNotFound))
+	}
+}
+
+// authorized reports whether the last field of header is a token issued by a login
+func (f *fakeMattermost) authorized(header string) bool {
+	fields := strings.Fields(header)
+	if len(fields) == 0 {
+		return false
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.tokens[fields[len(fields)-1]]
+}
+
+// CreateAccessToken returns a new personal access token of the bot user
+func (f *fakeMattermost) CreateAccessToken() string {
+	token := model.NewId()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.tokens[token] = true
+	return token
+}
+
+// ExpireSessions invalidates all tokens issued so far, including access tokens
+func (f *fakeMattermost) ExpireSessions() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.tokens = make(map[string]bool)
+}
+
+// AddCustomEmoji creates a custom emoji called name
+func (f *fakeMattermost) AddCustomEmoji(name string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.emojis[name] = true
+}
+
+// EmojiLookups returns the number of custom emojis looked up by name
+func (f *fakeMattermost) EmojiLookups() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.emojiLookups
+}
+
+// FailReaction makes saving reactions with emoji fail with the given status codes, one after the other. The last status code is kept, 0 saves the reaction.
+func (f *fakeMattermost) FailReaction(emoji string, statuses ...int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.reactionFailures[emoji] = statuses
+}
+
+// DirectChannel returns the members of the direct channel with channelID
+func (f *fakeMattermost) DirectChannel(channelID string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.directChannels[channelID]
+}
+
+// SetReactionDelay makes saving a reaction take at least d
+func (f *fakeMattermost) SetReactionDelay(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.reactionDelay = d
+}
+
+// SetPostDelay makes creating a post take at least d
+func (f *fakeMattermost) SetPostDelay(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.postDelay = d
+}
+
+// SetAuthDelay makes logins and looking up the bot user take at least d
+func (f *fakeMattermost) SetAuthDelay(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.authDelay = d
+}
+
+// DelayReactionList makes the next request for the reactions of a post take at least d.
+// The reactions are read after the delay.
+func (f *fakeMattermost) DelayReactionList(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.reactionListDelays = append(f.reactionListDelays, d)
+}
+
+// Logins returns the number of successful logins
+func (f *fakeMattermost) Logins() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.logins
+}
+
+// Close closes all websocket connections and shuts down the server
+func (f *fakeMattermost) Close() {
+	f.mu.Lock()
+	for _, s := range f.sockets {
+		s.conn.Close()
+	}
+	f.sockets = nil
+	f.mu.Unlock()
+	f.Server.Close()
+}
+
+// Posts returns all posts created so far in the order they were created
+func (f *fakeMattermost) Posts() []*model.Post {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var posts []*model.Post
+	for _, id := range f.order {
+		posts = append(posts, f.posts[id])
+	}
+	return posts
+}
+
+// AddReaction adds a reaction of the user with userID to the post with postID
+func (f *fakeMattermost) AddReaction(postID, userID, emoji string) {
+	f.saveReaction(&model.Reaction{UserId: userID, PostId: postID, EmojiName: emoji})
+}
+
+// AddReactionSilently adds a reaction like AddReaction, but doesn't send a websocket event
+func (f *fakeMattermost) AddReactionSilently(postID, userID, emoji string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.reactions[postID] = append(f.reactions[postID], &model.Reaction{UserId: userID, PostId: postID, EmojiName: emoji, CreateAt: model.GetMillis()})
+}
+
+// Post returns the post with postID
+func (f *fakeMattermost) Post(postID string) *model.Post {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	post := *f.posts[postID]
+	return &post
+}
+
+// Patches returns the number of patched posts
+func (f *fakeMattermost) Patches() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.patches
+}
+
+// UserReactions returns the emoji names of the reactions of the user with userID on the post with postID
+func (f *fakeMattermost) UserReactions(postID, userID string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	emojis := []string{}
+	for _, r := range f.reactions[postID] {
+		if r.UserId == userID {
+			emojis = append(emojis, r.EmojiName)
+		}
+	}
+	return emojis
+}
+
+// Connected returns the number of open websocket connections
+func (f *fakeMattermost) Connected() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.sockets)
+}
+
+func (f *fakeMattermost) saveReaction(reaction *model.Reaction) {
+	f.mu.Lock()
+	// Make sure reactions are ordered by their creation time
+	time.Sleep(time.Millisecond)
+	reaction.CreateAt = model.GetMillis()
+	f.reactions[reaction.PostId] = append(f.reactions[reaction.PostId], reaction)
+	f.mu.Unlock()
+	f.broadcast(model.WEBSOCKET_EVENT_REACTION_ADDED, reaction)
+}
+
+func (f *fakeMattermost) deleteReaction(postID, userID, emoji string) {
+	f.mu.Lock()
+	var deleted *model.Reaction
+	var kept []*model.Reaction
+	for _, r := range f.reactions[postID] {
+		if r.UserId == userID && r.EmojiName == emoji {
+			deleted = r
+			continue
+		}
+		kept = append(kept, r)
+	}
+	f.reactions[postID] = kept
+	f.mu.Unlock()
+	if deleted != nil {
+		f.broadcast(model.WEBSOCKET_EVENT_REACTION_REMOVED, deleted)
+	}
+}
+
+func (f *fakeMattermost) broadcast(event string, reaction *model.Reaction) {
+	e := model.NewWebSocketEvent(event, "", "", "", nil)
+	e.Add("reaction", reaction.ToJson())
+	f.mu.Lock()
+	sockets := append([]*fakeSocket{}, f.sockets...)
+	f.mu.Unlock()
+	for _, s := range sockets {
+		s.mu.Lock()
+		s.conn.WriteMessage(websocket.TextMessage, []byte(e.ToJson()))
+		s.mu.Unlock()
+	}
+}
+
+// Reactions returns the emoji names of all reactions on the post with postID
+func (f *fakeMattermost) Reactions(postID string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var emojis []string
+	for _, r := range f.reactions[postID] {
+		emojis = append(emojis, r.EmojiName)
+	}
+	return emojis
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// waitFor polls cond until it returns true or a second has passed
+func waitFor(cond func() bool) bool {
+	for try := 0; try < 100; try++ {
+		if cond() {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return false
+}
@@ -0,0 +1,135 @@
+package poll
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/mattermost/mattermost-server/model"
+)
+
+const (
+	// VotePath is the path Mattermost sends the button clicks of anonymous polls to
+	VotePath = "/poll/vote"
+	// ResponseTextVoted is the ephemeral message which is shown to the user after the vote was counted. It is formatted with the chosen emoji.
+	ResponseTextVoted = "Your vote for :%s: has been counted."
+	// ErrorVoteFailed is an error message and is used, if a vote couldn`t be counted
+	ErrorVoteFailed = `An error occurred while counting your vote. Try again later.`
+	// ErrorVoteClosed is an error message and is used, if somebody votes after the poll was ended
+	ErrorVoteClosed = `This poll has ended. Your vote wasn't counted.`
+)
+
+// Vote handles a click on a button of an anonymous poll. The vote is recorded and the counts in the poll post are updated.
+func (ps *Server) Vote(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var req model.PostActionIntegrationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	pollID, _ := req.Context["poll_id"].(string)
+	secret, _ := req.Context["secret"].(string)
+	option, _ := req.Context["option"].(string)
+	if len(req.UserId) == 0 || len(pollID) == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	lg := ps.Logger().With("request_id", model.NewId(), "poll_id", pollID, "user_id", req.UserId)
+	var response model.PostActionIntegrationResponse
+	p, err := ps.vote(pollID, secret, req.UserId, option)
+	switch err {
+	case nil:
+		lg.Debug("Vote counted")
+		response.Update = ps.pollPost(p, countAnonymousVotes(p))
+		response.EphemeralText = fmt.Sprintf(ResponseTextVoted, option)
+	case ErrPollNotFound:
+		w.WriteHeader(http.StatusNotFound)
+		return
+	case errPollClosed:
+		response.EphemeralText = ErrorVoteClosed
+	default:
+		lg.Error("Failed to count vote", "err", err)
+		response.EphemeralText = ErrorVoteFailed
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(&response); err != nil {
+		lg.Warn("Failed to write response", "err", err)
+	}
+}
+
+// vote records that the user with userID chose option in the anonymous poll with pollID. A previous vote of the user is replaced.
+func (ps *Server) vote(pollID, secret, userID, option string) (*Poll, error) {
+	defer ps.lockPoll(pollID)()
+
+	p, err := ps.Store.Get(pollID)
+	if err != nil {
+		return nil, err
+	}
+	// A poll which isn't anonymous or a wrong secret are handled like a missing poll to not reveal anything
+	if !p.Anonymous || p.Secret != secret {
+		return nil, ErrPollNotFound
+	}
+	if p.State == PollStateClosed {
+		return nil, errPollClosed
+	}
+	if !p.hasOption(option) {
+		return nil, fmt.Errorf("Error: Option %q isn't part of poll %s", option, p.ID)
+	}
+	if p.Votes == nil {
+		p.Votes = make(map[string]string)
+	}
+	p.Votes[userID] = option
+	if err := ps.Store.Save(p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// voteAttachments renders the buttons of an anonymous poll. Every button shows the number of votes for its option.
+// Closed polls have no buttons anymore.
+func (ps *Server) voteAttachments(p *Poll, results []Result) []*model.SlackAttachment {
+	total := 0
+	actions := make([]*model.PostAction, len(results))
+	for i, r := range results {
+		total += r.Votes
+		actions[i] = &model.PostAction{
+			Id:   fmt.Sprintf("option%d", i),
+			Name: fmt.Sprintf("%s %d", p.optionText(r.Emoji), r.Votes),
+			Integration: &model.PostActionIntegration{
+				URL: ps.Conf().URL + VotePath,
+				Context: model.StringInterface{
+					"poll_id": p.ID,
+					"secret":  p.Secret,
+					"option":  r.Emoji,
+				},
+			},
+		}
+	}
+	attachment := &model.SlackAttachment{
+		Text:    fmt.Sprintf("%d %s. Votes are anonymous.", total, pluralize(total, "vote", "votes")),
+		Actions: actions,
+	}
+	if p.State == PollStateClosed {
+		attachment.Actions = nil
+	}
+	return []*model.SlackAttachment{attachment}
+}
+
+// countAnonymousVotes counts the recorded votes for every option of an anonymous poll
+func countAnonymousVotes(p *Poll) []Result {
+	votes := make(map[string]int)
+	for _, option := range p.Votes {
+		votes[option]++
+	}
+	results := make([]Result, len(p.Options))
+	for i, o := range p.Options {
+		results[i] = Result{Emoji: o, Votes: votes[o]}
+	}
+	return results
+}
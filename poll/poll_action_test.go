@@ -0,0 +1,173 @@
+package poll_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kaakaa/matterpoll-emoji/poll"
+	"github.com/mattermost/mattermost-server/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnonymousPoll(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ps, mm := newTestServer(require)
+	defer mm.Close()
+	c := ps.Conf()
+	c.URL = "http://matterpoll.example.com"
+
+	creator := model.NewId()
+	payload := fmt.Sprintf("token=%s&user_id=%s&channel_id=%s&text=\"%s\" :pizza: :sushi: --anonymous", c.Token, creator, model.NewId(), "Salary band?")
+	sendHttpRequest(require, ps, payload)
+	posts := mm.Posts()
+	require.Len(posts, 1)
+	polls, err := ps.Store.List()
+	require.Nil(err)
+	require.Len(polls, 1)
+	p := polls[0]
+	assert.True(p.Anonymous)
+	assert.Empty(mm.Reactions(p.PostID))
+
+	attachments := posts[0].Attachments()
+	require.Len(attachments, 1)
+	require.Len(attachments[0].Actions, 2)
+	action := attachments[0].Actions[0]
+	assert.Equal(":pizza: 0", action.Name)
+	require.NotNil(action.Integration)
+	assert.Equal("http://matterpoll.example.com"+poll.VotePath, action.Integration.URL)
+	context := action.Integration.Context
+
+	voter := model.NewId()
+	response := sendVote(require, ps, voter, context)
+	assert.Equal(fmt.Sprintf(poll.ResponseTextVoted, "pizza"), response.EphemeralText)
+	require.NotNil(response.Update)
+	assert.Equal(":pizza: 1", response.Update.Attachments()[0].Actions[0].Name)
+	assert.Equal(":sushi: 0", response.Update.Attachments()[0].Actions[1].Name)
+	assert.NotContains(response.Update.ToJson(), voter)
+
+	// Voting again replaces the previous vote
+	context = attachments[0].Actions[1].Integration.Context
+	response = sendVote(require, ps, voter, context)
+	require.NotNil(response.Update)
+	assert.Equal(":pizza: 0", response.Update.Attachments()[0].Actions[0].Name)
+	assert.Equal(":sushi: 1", response.Update.Attachments()[0].Actions[1].Name)
+	sendVote(require, ps, model.NewId(), context)
+
+	// The secret of the poll is required
+	forged := model.StringInterface{"poll_id": p.ID, "secret": model.NewId(), "option": "pizza"}
+	recorder := postVote(require, ps, voter, forged)
+	assert.Equal(http.StatusNotFound, recorder.Code)
+
+	payload = fmt.Sprintf("token=%s&user_id=%s&text=end %s", c.Token, creator, p.ID)
+	sendHttpRequest(require, ps, payload)
+	posts = mm.Posts()
+	require.Len(posts, 2)
+	assert.Contains(posts[1].Message, "| :pizza: | 0 | 0% |")
+	assert.Contains(posts[1].Message, "| :sushi: | 2 | 100% |")
+	// The buttons are removed from the poll post
+	attachments = mm.Post(p.PostID).Attachments()
+	require.Len(attachments, 1)
+	assert.Empty(attachments[0].Actions)
+	assert.Equal("2 votes. Votes are anonymous.", attachments[0].Text)
+	assert.Contains(mm.Post(p.PostID).Message, "_This poll has ended._")
+
+	response = sendVote(require, ps, model.NewId(), context)
+	assert.Equal(poll.ErrorVoteClosed, response.EphemeralText)
+	assert.Nil(response.Update)
+}
+
+func TestVoteWhileOtherPollCloses(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ps, mm := newTestServer(require)
+	defer mm.Close()
+	c := ps.Conf()
+	c.URL = "http://matterpoll.example.com"
+
+	creator := model.NewId()
+	for i := 0; i < 2; i++ {
+		payload := fmt.Sprintf("token=%s&user_id=%s&channel_id=%s&text=\"%s\" :pizza: :sushi: --anonymous", c.Token, creator, model.NewId(), "Salary band?")
+		sendHttpRequest(require, ps, payload)
+	}
+	posts := mm.Posts()
+	require.Len(posts, 2)
+	closing, open := posts[0], posts[1]
+
+	// Posting the results of the first poll hangs, while somebody votes in the second poll
+	mm.SetPostDelay(time.Second)
+	p, err := ps.Store.GetByPost(closing.Id)
+	require.Nil(err)
+	ended := make(chan struct{})
+	go func() {
+		defer close(ended)
+		payload := fmt.Sprintf("token=%s&user_id=%s&text=end %s", c.Token, creator, p.ID)
+		sendHttpRequest(require, ps, payload)
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	start := time.Now()
+	response := sendVote(require, ps, model.NewId(), open.Attachments()[0].Actions[0].Integration.Context)
+	assert.Equal(fmt.Sprintf(poll.ResponseTextVoted, "pizza"), response.EphemeralText)
+	assert.True(time.Since(start) < 500*time.Millisecond)
+	<-ended
+}
+
+func TestAnonymousPollDisabled(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	c, err := getConfig("sample_conf.json")
+	require.Nil(err)
+	ps, err := poll.NewServer(c)
+	require.Nil(err)
+
+	payload := fmt.Sprintf("token=%s&user_id=%s&channel_id=%s&text=\"%s\" :pizza: :sushi: --anonymous", c.Token, model.NewId(), model.NewId(), "Salary band?")
+	response, _ := sendHttpRequest(require, ps, payload)
+	assert.Equal(poll.ErrorAnonymousDisabled, response.Text)
+}
+
+func TestVoteBadRequest(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	c, err := getConfig("sample_conf.json")
+	require.Nil(err)
+	ps, err := poll.NewServer(c)
+	require.Nil(err)
+
+	r := httptest.NewRequest(http.MethodPost, poll.VotePath, strings.NewReader("{"))
+	recorder := httptest.NewRecorder()
+	ps.Vote(recorder, r)
+	assert.Equal(http.StatusBadRequest, recorder.Code)
+
+	r = httptest.NewRequest(http.MethodGet, poll.VotePath, nil)
+	recorder = httptest.NewRecorder()
+	ps.Vote(recorder, r)
+	assert.Equal(http.StatusMethodNotAllowed, recorder.Code)
+}
+
+func postVote(require *require.Assertions, ps *poll.Server, userID string, context model.StringInterface) *httptest.ResponseRecorder {
+	req := &model.PostActionIntegrationRequest{UserId: userID, Context: context}
+	r := httptest.NewRequest(http.MethodPost, poll.VotePath, strings.NewReader(req.ToJson()))
+	r.Header.Set("Content-Type", "application/json")
+	recorder := httptest.NewRecorder()
+	ps.Vote(recorder, r)
+	return recorder
+}
+
+func sendVote(require *require.Assertions, ps *poll.Server, userID string, context model.StringInterface) *model.PostActionIntegrationResponse {
+	recorder := postVote(require, ps, userID, context)
+	require.Equal(http.StatusOK, recorder.Code)
+	var response model.PostActionIntegrationResponse
+	require.Nil(json.NewDecoder(recorder.Body).Decode(&response))
+	return &response
+}
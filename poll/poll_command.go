@@ -0,0 +1,253 @@
+package poll
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/mattermost/mattermost-server/model"
+)
+
+// Version is the version of Matterpoll shown by `/poll version`. It is set by main.
+var Version = "dev"
+
+const (
+	// ResponseTextVersion is the ephemeral message which is sent back for `/poll version`. It is formatted with the version.
+	ResponseTextVersion = "Matterpoll %s"
+	// ResponseTextNoPolls is the ephemeral message which is sent back for `/poll list`, if there are no open polls in the channel
+	ResponseTextNoPolls = "There are no open polls in this channel."
+	// ErrorUnknownCommand is an error message and is used, if the slash command starts with an unknown subcommand. It is formatted with the subcommand.
+	ErrorUnknownCommand = "Unknown command `%s`. Type `/poll help` to see all commands."
+	// ErrorResultsWrongFormat is an error message and is used, if the results command isn`t formated correct
+	ErrorResultsWrongFormat = `The message format is wrong. Try this instead: ` + backTick + `/poll results <poll id>` + backTick
+	// ErrorResultsFailed is an error message and is used, if the results of a poll couldn`t be counted
+	ErrorResultsFailed = `An error occurred while counting the votes. Ask your administrator to check the Matterpoll logs.`
+)
+
+// subcommand describes a subcommand of /poll for the help
+type subcommand struct {
+	name string
+	// usage shows the arguments of the subcommand
+	usage string
+	// summary is shown in the list of all subcommands
+	summary string
+	// help is shown by `/poll help <name>`
+	help string
+}
+
+var subcommands = []subcommand{
+	{
+		name:    "create",
+		usage:   "/poll create \"Question\" :emoji1: :emoji2: [options]",
+		summary: "Post a new poll. `create` can be left out",
+		help: "Posts a poll with the emojis as options. Users vote by adding reactions. The question can be quoted with `\"`, `'` or `` ` ``. " +
+			"Emojis can be pasted as characters like 🍕, too. " +
+			"A quoted label after an emoji explains the option, e.g. `:one: \"Thai place\"`. " +
+			"Options given only as quoted text are numbered automatically, e.g. `/poll \"Lunch?\" \"Pizza\" \"Sushi\"`.\n\n" +
+			"Options:\n" +
+			"* `--duration=2h` ends the poll after the given time\n" +
+			"* `--until=2026-10-20T17:00` ends the poll at the given time\n" +
+			"* `--single` allows only one vote per user\n" +
+			"* `--anonymous` shows buttons instead of reactions, so that nobody sees who voted for which option",
+	},
+	{
+		name:    "list",
+		usage:   "/poll list",
+		summary: "List the open polls in this channel",
+		help:    "Lists the ID, the question and the deadline of every open poll in this channel.",
+	},
+	{
+		name:    "results",
+		usage:   "/poll results <poll id>",
+		summary: "Show the current results of a poll",
+		help:    "Shows the current results of a poll only to you. Run it in the channel of the poll. The poll stays open.",
+	},
+	{
+		name:    "end",
+		usage:   "/poll end <poll id>",
+		summary: "End a poll and post its results",
+		help:    "Ends a poll and posts the results as a reply to the poll. Only the creator of a poll can end it.",
+	},
+	{
+		name:    "version",
+		usage:   "/poll version",
+		summary: "Show the version of Matterpoll",
+		help:    "Shows the version of the Matterpoll server.",
+	},
+	{
+		name:    "help",
+		usage:   "/poll help [command]",
+		summary: "Show this help or the help of a command",
+		help:    "Shows all commands. `/poll help <command>` shows the details of a command.",
+	},
+}
+
+// dispatch answers the slash command in form with the subcommand named by its first word. Polls are created by default.
+// The command is counted in the metrics and logged with its outcome.
+func (ps *Server) dispatch(lg *Logger, form url.Values, instance string) *model.CommandResponse {
+	command, response, outcome := ps.runCommand(lg, form, instance)
+	ps.metrics.countCommand(command, outcome)
+	lg.Info("Slash command answered", "command", command, "outcome", outcome, "user_id", form.Get("user_id"), "channel_id", form.Get("channel_id"))
+	return response
+}
+
+// runCommand answers the slash command in form. It returns the name of the subcommand, which is "unknown" for unknown subcommands,
+// the answer and the outcome of the command.
+func (ps *Server) runCommand(lg *Logger, form url.Values, instance string) (string, *model.CommandResponse, string) {
+	name := commandName(form)
+	switch {
+	case len(name) == 0 && len(strings.TrimSpace(form.Get("text"))) == 0:
+		name = "help"
+	case len(name) == 0:
+		name = "create"
+	case name == "create":
+		f := url.Values{}
+		for key, values := range form {
+			f[key] = values
+		}
+		f.Set("text", strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(form.Get("text")), name)))
+		form = f
+	}
+
+	var response *model.CommandResponse
+	var outcome string
+	switch name {
+	case "create":
+		response, outcome = ps.create(lg, form, instance)
+	case "end":
+		response, outcome = ps.end(lg, form, instance)
+	case "list":
+		response, outcome = ps.commandList(lg, form, instance)
+	case "results":
+		response, outcome = ps.commandResults(lg, form, instance)
+	case "version":
+		response, outcome = ps.commandVersion(form, instance)
+	case "help":
+		response, outcome = ps.commandHelp(form, instance)
+	default:
+		return "unknown", newResponse(fmt.Sprintf(ErrorUnknownCommand, name)), outcomeUnknownCommand
+	}
+	return name, response, outcome
+}
+
+// commandRequest parses form and verifies its token
+func (ps *Server) commandRequest(form url.Values, instance string) (*CommandRequest, *Instance, error) {
+	req, err := NewCommandRequest(form)
+	if err != nil {
+		return nil, nil, err
+	}
+	in, _, err := ps.checkToken(instance, req.Token, req.TeamID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return req, in, nil
+}
+
+func (ps *Server) commandHelp(form url.Values, instance string) (*model.CommandResponse, string) {
+	req, _, err := ps.commandRequest(form, instance)
+	if err != nil {
+		return requestError(err)
+	}
+	if len(req.Args) != 0 {
+		for _, cmd := range subcommands {
+			if cmd.name == req.Args[0] {
+				return newResponse(fmt.Sprintf("`%s`\n\n%s", cmd.usage, cmd.help)), outcomeOK
+			}
+		}
+		return newResponse(fmt.Sprintf(ErrorUnknownCommand, req.Args[0])), outcomeUnknownCommand
+	}
+	var b bytes.Buffer
+	b.WriteString("Matterpoll commands:\n")
+	for _, cmd := range subcommands {
+		fmt.Fprintf(&b, "* `%s` %s\n", cmd.usage, cmd.summary)
+	}
+	return newResponse(b.String()), outcomeOK
+}
+
+func (ps *Server) commandVersion(form url.Values, instance string) (*model.CommandResponse, string) {
+	if _, _, err := ps.commandRequest(form, instance); err != nil {
+		return requestError(err)
+	}
+	return newResponse(fmt.Sprintf(ResponseTextVersion, Version)), outcomeOK
+}
+
+func (ps *Server) commandList(lg *Logger, form url.Values, instance string) (*model.CommandResponse, string) {
+	req, in, err := ps.commandRequest(form, instance)
+	if err != nil {
+		return requestError(err)
+	}
+	polls, err := ps.Store.List()
+	if err != nil {
+		lg.Error("Failed to list polls", "err", err)
+		return newResponse(ErrorResultsFailed), outcomeFailed
+	}
+	var b bytes.Buffer
+	for _, p := range polls {
+		if p.State != PollStateOpen || p.Instance != in.Name || p.ChannelID != req.ChannelID {
+			continue
+		}
+		fmt.Fprintf(&b, "* `%s` **%s**", p.ID, p.Question)
+		if !p.ExpiresAt.IsZero() {
+			fmt.Fprintf(&b, " ends at %s", p.ExpiresAt.Format("2006-01-02 15:04 MST"))
+		}
+		b.WriteString("\n")
+	}
+	if b.Len() == 0 {
+		return newResponse(ResponseTextNoPolls), outcomeOK
+	}
+	return newResponse("Open polls in this channel:\n" + b.String()), outcomeOK
+}
+
+func (ps *Server) commandResults(lg *Logger, form url.Values, instance string) (*model.CommandResponse, string) {
+	req, in, err := ps.commandRequest(form, instance)
+	if err != nil {
+		return requestError(err)
+	}
+	if len(req.Args) != 1 {
+		return newResponse(ErrorResultsWrongFormat), outcomeWrongFormat
+	}
+	lg = lg.With("poll_id", req.Args[0])
+	p, err := ps.Store.Get(req.Args[0])
+	// Polls of other channels are hidden, so that their results can't be read outside of private channels
+	if err == ErrPollNotFound || (err == nil && (p.Instance != in.Name || p.ChannelID != req.ChannelID)) {
+		lg.Debug("Poll not found")
+		return newResponse(ErrorPollNotFound), outcomeNotFound
+	}
+	if err != nil {
+		lg.Error("Failed to look up poll", "err", err)
+		return newResponse(ErrorResultsFailed), outcomeFailed
+	}
+	results, err := ps.currentResults(lg, p)
+	if err != nil {
+		lg.Error("Failed to count votes", "err", err)
+		return newResponse(ErrorResultsFailed), outcomeFailed
+	}
+	total := 0
+	for _, r := range results {
+		total += r.Votes
+	}
+	state := "Current results"
+	if p.State == PollStateClosed {
+		state = "Final results"
+	}
+	return newResponse(fmt.Sprintf("%s of **%s** with %d %s:\n\n%s", state, p.Question, total, pluralize(total, "vote", "votes"), formatTally(results))), outcomeOK
+}
+
+// currentResults counts the votes of p
+func (ps *Server) currentResults(lg *Logger, p *Poll) ([]Result, error) {
+	if p.Anonymous {
+		return countAnonymousVotes(p), nil
+	}
+	var results []Result
+	err := ps.withClient(lg, p.Instance, func(c *model.Client4, bot *model.User) error {
+		reactions, apiResponse := c.GetReactions(p.PostID)
+		if err := checkResponse(apiResponse, http.StatusOK, "Failed to fetch reactions"); err != nil {
+			return err
+		}
+		results = countVotes(p, reactions, bot.Id)
+		return nil
+	})
+	return results, err
+}
@@ -0,0 +1,134 @@
+package poll_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/kaakaa/matterpoll-emoji/poll"
+	"github.com/mattermost/mattermost-server/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommandHelp(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	c, err := getConfig("sample_conf.json")
+	require.Nil(err)
+	ps, err := poll.NewServer(c)
+	require.Nil(err)
+
+	for _, text := range []string{"", "help"} {
+		payload := fmt.Sprintf("token=%s&user_id=%s&text=%s", c.Token, model.NewId(), text)
+		response, _ := sendHttpRequest(require, ps, payload)
+		assert.Equal(model.COMMAND_RESPONSE_TYPE_EPHEMERAL, response.ResponseType)
+		for _, sub := range []string{"create", "list", "results", "end", "version", "help"} {
+			assert.Contains(response.Text, "/poll "+sub)
+		}
+	}
+
+	payload := fmt.Sprintf("token=%s&user_id=%s&text=help create", c.Token, model.NewId())
+	response, _ := sendHttpRequest(require, ps, payload)
+	assert.Equal(model.COMMAND_RESPONSE_TYPE_EPHEMERAL, response.ResponseType)
+	assert.Contains(response.Text, "--anonymous")
+	assert.NotContains(response.Text, "/poll list")
+
+	tests := []struct {
+		Token    string
+		Text     string
+		Expected string
+	}{
+		{c.Token, "help vote", fmt.Sprintf(poll.ErrorUnknownCommand, "vote")},
+		{c.Token, "vote " + model.NewId(), fmt.Sprintf(poll.ErrorUnknownCommand, "vote")},
+		{c.Token, "version", fmt.Sprintf(poll.ResponseTextVersion, poll.Version)},
+		{model.NewId(), "help", poll.ErrorTokenMissmatch},
+		{model.NewId(), "version", poll.ErrorTokenMissmatch},
+	}
+	for _, test := range tests {
+		payload := fmt.Sprintf("token=%s&user_id=%s&text=%s", test.Token, model.NewId(), test.Text)
+		response, _ := sendHttpRequest(require, ps, payload)
+		assert.Equal(model.COMMAND_RESPONSE_TYPE_EPHEMERAL, response.ResponseType)
+		assert.Equal(test.Expected, response.Text)
+	}
+}
+
+func TestCommandCreate(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ps, mm := newTestServer(require)
+	defer mm.Close()
+	c := ps.Conf()
+
+	payload := fmt.Sprintf("token=%s&user_id=%s&channel_id=%s&text=create \"Lunch?\" :pizza: :sushi:", c.Token, model.NewId(), model.NewId())
+	response, _ := sendHttpRequest(require, ps, payload)
+	assert.Equal(model.COMMAND_RESPONSE_TYPE_EPHEMERAL, response.ResponseType)
+	polls, err := ps.Store.List()
+	require.Nil(err)
+	require.Len(polls, 1)
+	assert.Equal("Lunch?", polls[0].Question)
+	assert.Equal([]string{"pizza", "sushi"}, polls[0].Options)
+}
+
+func TestCommandListAndResults(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ps, mm := newTestServer(require)
+	defer mm.Close()
+	c := ps.Conf()
+
+	channelID := model.NewId()
+	payload := fmt.Sprintf("token=%s&user_id=%s&channel_id=%s&text=list", c.Token, model.NewId(), channelID)
+	response, _ := sendHttpRequest(require, ps, payload)
+	assert.Equal(model.COMMAND_RESPONSE_TYPE_EPHEMERAL, response.ResponseType)
+	assert.Equal(poll.ResponseTextNoPolls, response.Text)
+
+	p := createTestPoll(require, mm, ps, model.NewId(), ":pizza: :sushi:")
+	mm.AddReactionSilently(p.PostID, model.NewId(), "pizza")
+	mm.AddReactionSilently(p.PostID, model.NewId(), "pizza")
+	mm.AddReactionSilently(p.PostID, model.NewId(), "sushi")
+
+	payload = fmt.Sprintf("token=%s&user_id=%s&channel_id=%s&text=list", c.Token, model.NewId(), p.ChannelID)
+	response, _ = sendHttpRequest(require, ps, payload)
+	assert.Contains(response.Text, p.ID)
+	assert.Contains(response.Text, p.Question)
+
+	// Polls of other channels are not listed
+	payload = fmt.Sprintf("token=%s&user_id=%s&channel_id=%s&text=list", c.Token, model.NewId(), channelID)
+	response, _ = sendHttpRequest(require, ps, payload)
+	assert.Equal(poll.ResponseTextNoPolls, response.Text)
+
+	payload = fmt.Sprintf("token=%s&user_id=%s&channel_id=%s&text=results %s", c.Token, model.NewId(), p.ChannelID, p.ID)
+	response, _ = sendHttpRequest(require, ps, payload)
+	assert.Equal(model.COMMAND_RESPONSE_TYPE_EPHEMERAL, response.ResponseType)
+	assert.Contains(response.Text, "Current results")
+	assert.Contains(response.Text, "with 3 votes")
+	assert.Contains(response.Text, ":pizza: `███████░░░` 2 (67%)")
+	assert.Contains(response.Text, ":sushi: `███░░░░░░░` 1 (33%)")
+	// The results are only shown to the user who asked
+	assert.Len(mm.Posts(), 1)
+
+	tests := []struct {
+		Token    string
+		Text     string
+		Expected string
+	}{
+		{c.Token, "results", poll.ErrorResultsWrongFormat},
+		{c.Token, "results one two", poll.ErrorResultsWrongFormat},
+		{c.Token, "results " + model.NewId(), poll.ErrorPollNotFound},
+		{model.NewId(), "results " + p.ID, poll.ErrorTokenMissmatch},
+		{model.NewId(), "list", poll.ErrorTokenMissmatch},
+	}
+	for _, test := range tests {
+		payload := fmt.Sprintf("token=%s&user_id=%s&channel_id=%s&text=%s", test.Token, model.NewId(), p.ChannelID, test.Text)
+		response, _ := sendHttpRequest(require, ps, payload)
+		assert.Equal(test.Expected, response.Text)
+	}
+
+	// The results of polls in other channels can't be read
+	payload = fmt.Sprintf("token=%s&user_id=%s&channel_id=%s&text=results %s", c.Token, model.NewId(), channelID, p.ID)
+	response, _ = sendHttpRequest(require, ps, payload)
+	assert.Equal(poll.ErrorPollNotFound, response.Text)
+}
@@ -0,0 +1,289 @@
+package poll
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mattermost/mattermost-server/model"
+)
+
+const (
+	zeroWidthJoiner = '\u200D'
+	combiningKeycap = '\u20E3'
+)
+
+// unicodeEmoji is an entry of unicodeEmojis
+type unicodeEmoji struct {
+	name string
+	// tones is true, if the emoji can be combined with a skin tone modifier
+	tones bool
+}
+
+// skinTones maps the skin tone modifiers to the suffixes of the short names of emojis with this tone
+var skinTones = map[rune]string{
+	'\U0001F3FB': "light_skin_tone",
+	'\U0001F3FC': "medium_light_skin_tone",
+	'\U0001F3FD': "medium_skin_tone",
+	'\U0001F3FE': "medium_dark_skin_tone",
+	'\U0001F3FF': "dark_skin_tone",
+}
+
+// emojiNames converts the Unicode emojis in word to their short names. It returns false, if word contains anything else than known emojis.
+func emojiNames(word string) ([]string, bool) {
+	var names []string
+	for len(word) != 0 {
+		sequence, rest := nextEmoji(word)
+		name, ok := emojiName(sequence)
+		if !ok {
+			return nil, false
+		}
+		names = append(names, name)
+		word = rest
+	}
+	return names, len(names) != 0
+}
+
+// nextEmoji splits the first emoji off s. An emoji is a character with its modifiers, a keycap, a flag or a sequence of those joined by zero width joiners.
+func nextEmoji(s string) (string, string) {
+	first, end := utf8.DecodeRuneInString(s)
+	if isRegionalIndicator(first) {
+		// A flag is a pair of regional indicators, the next pair starts the next flag
+		if r, size := utf8.DecodeRuneInString(s[end:]); isRegionalIndicator(r) {
+			end += size
+		}
+	}
+	for end < len(s) {
+		r, size := utf8.DecodeRuneInString(s[end:])
+		switch {
+		case isVariationSelector(r) || r == combiningKeycap:
+		case skinTones[r] != "":
+		case r == zeroWidthJoiner && end+size < len(s):
+			// The joiner is always followed by the next character of the sequence
+			_, next := utf8.DecodeRuneInString(s[end+size:])
+			size += next
+		default:
+			return s[:end], s[end:]
+		}
+		end += size
+	}
+	return s, ""
+}
+
+// emojiName looks up the short name of a single emoji. A skin tone is appended to the name of the emoji.
+func emojiName(sequence string) (string, bool) {
+	var key []rune
+	tone := ""
+	for _, r := range sequence {
+		switch {
+		case isVariationSelector(r):
+		case skinTones[r] != "":
+			if len(tone) != 0 && tone != skinTones[r] {
+				return "", false
+			}
+			tone = skinTones[r]
+		default:
+			key = append(key, r)
+		}
+	}
+	e, ok := unicodeEmojis[string(key)]
+	if !ok || (len(tone) != 0 && !e.tones) {
+		return "", false
+	}
+	if len(tone) != 0 {
+		return e.name + "_" + tone, true
+	}
+	return e.name, true
+}
+
+func isVariationSelector(r rune) bool {
+	return r == '\uFE0E' || r == '\uFE0F'
+}
+
+func isRegionalIndicator(r rune) bool {
+	return r >= '\U0001F1E6' && r <= '\U0001F1FF'
+}
+
+// containsNonASCII reports whether s contains a character outside of ASCII, e.g. an emoji
+func containsNonASCII(s string) bool {
+	return strings.IndexFunc(s, func(r rune) bool { return r >= utf8.RuneSelf }) >= 0
+}
+
+// emojiMissTTL is the time an unknown custom emoji is remembered. Custom emojis which exist are remembered until the connection settings change.
+const emojiMissTTL = time.Minute
+
+// maxEmojiSuggestions is the maximum number of names suggested for an unknown emoji
+const maxEmojiSuggestions = 3
+
+// emojiCacheEntry is the result of looking up a custom emoji
+type emojiCacheEntry struct {
+	exists bool
+	// expires is the time the lookup is repeated, if the emoji didn't exist
+	expires time.Time
+}
+
+// unknownEmojiError is returned by validateEmojis, if some emojis don't exist. Its message is shown to the user.
+type unknownEmojiError struct {
+	names []string
+}
+
+func (e *unknownEmojiError) Error() string {
+	var unknown []string
+	for _, name := range e.names {
+		text := "`:" + name + ":`"
+		if suggestions := suggestEmojis(name); len(suggestions) != 0 {
+			text += " (did you mean `:" + strings.Join(suggestions, ":`, `:") + ":`?)"
+		}
+		unknown = append(unknown, text)
+	}
+	return fmt.Sprintf(ErrorUnknownEmojis, strings.Join(unknown, ", "))
+}
+
+// tonedEmojis are the names of the emojis which can be combined with a skin tone
+var tonedEmojis = func() map[string]bool {
+	names := make(map[string]bool)
+	for _, e := range unicodeEmojis {
+		if e.tones {
+			names[e.name] = true
+		}
+	}
+	return names
+}()
+
+// isSystemEmoji reports whether name is one of the emojis built into Mattermost
+func isSystemEmoji(name string) bool {
+	if systemEmojiNames[name] {
+		return true
+	}
+	for _, tone := range skinTones {
+		if base := strings.TrimSuffix(name, "_"+tone); base != name && tonedEmojis[base] {
+			return true
+		}
+	}
+	return false
+}
+
+// validateEmojis checks that every emoji is a system emoji or a custom emoji on the instance called name.
+// If some emojis don't exist, an unknownEmojiError is returned.
+func (ps *Server) validateEmojis(lg *Logger, c *model.Client4, name string, emojis []string) error {
+	var unknown []string
+	for _, e := range emojis {
+		if isSystemEmoji(e) {
+			continue
+		}
+		exists, err := ps.customEmojiExists(lg, c, name, e)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			unknown = append(unknown, e)
+		}
+	}
+	if len(unknown) != 0 {
+		return &unknownEmojiError{names: unknown}
+	}
+	return nil
+}
+
+// customEmojiExists looks up the custom emoji called emoji on the instance called name. The result is cached.
+// If the lookup fails for another reason than an expired session, the emoji is assumed to exist, so that polls can still be posted.
+func (ps *Server) customEmojiExists(lg *Logger, c *model.Client4, name string, emoji string) (bool, error) {
+	key := name + "/" + emoji
+	ps.emojiMu.Lock()
+	entry, ok := ps.emojis[key]
+	ps.emojiMu.Unlock()
+	if ok && (entry.exists || time.Now().Before(entry.expires)) {
+		return entry.exists, nil
+	}
+
+	r, appErr := c.DoApiGet(c.GetEmojisRoute()+"/name/"+url.PathEscape(emoji), "")
+	if r != nil {
+		r.Body.Close()
+	}
+	exists := true
+	if appErr != nil {
+		switch appErr.StatusCode {
+		case http.StatusNotFound, http.StatusNotImplemented:
+			// Mattermost answers with 501, if custom emojis are disabled
+			exists = false
+		case http.StatusUnauthorized:
+			return false, checkResponse(model.BuildErrorResponse(r, appErr), http.StatusOK, "Failed to look up emoji")
+		default:
+			lg.Warn("Failed to look up emoji", "emoji", emoji, "status", appErr.StatusCode, "err", appErr.Error())
+			return true, nil
+		}
+	}
+	ps.emojiMu.Lock()
+	ps.emojis[key] = emojiCacheEntry{exists: exists, expires: time.Now().Add(emojiMissTTL)}
+	ps.emojiMu.Unlock()
+	return exists, nil
+}
+
+// clearEmojis forgets all looked up custom emojis
+func (ps *Server) clearEmojis() {
+	ps.emojiMu.Lock()
+	defer ps.emojiMu.Unlock()
+	ps.emojis = make(map[string]emojiCacheEntry)
+}
+
+// suggestEmojis returns the names of the system emojis which are most similar to name
+func suggestEmojis(name string) []string {
+	type suggestion struct {
+		name     string
+		distance int
+	}
+	// Allow more typos in longer names
+	limit := 1 + len(name)/5
+	var suggestions []suggestion
+	for candidate := range systemEmojiNames {
+		if d := editDistance(name, candidate); d <= limit {
+			suggestions = append(suggestions, suggestion{candidate, d})
+		}
+	}
+	sort.Slice(suggestions, func(i, j int) bool {
+		if suggestions[i].distance != suggestions[j].distance {
+			return suggestions[i].distance < suggestions[j].distance
+		}
+		return suggestions[i].name < suggestions[j].name
+	})
+	var names []string
+	for i := 0; i < len(suggestions) && i < maxEmojiSuggestions; i++ {
+		names = append(names, suggestions[i].name)
+	}
+	return names
+}
+
+// editDistance is the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = minInt(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+func minInt(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
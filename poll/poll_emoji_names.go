@@ -0,0 +1,632 @@
+package poll
+
+import "strings"
+
+// systemEmojiNames are the names of the emojis built into Mattermost, including their aliases and the names used by other
+// emoji sets. The list rather contains too many names than too few, so that no system emoji is rejected.
+var systemEmojiNames = func() map[string]bool {
+	names := make(map[string]bool)
+	for _, name := range strings.Fields(systemEmojiList) {
+		names[name] = true
+	}
+	return names
+}()
+
+const systemEmojiList = `
++1 -1 100 1234 1st_place_medal 2nd_place_medal 3rd_place_medal 8ball a ab abacus abc abcd accept accordion
+adhesive_bandage admission_tickets adult adult_tone1 adult_tone2 adult_tone3 adult_tone4 adult_tone5
+aerial_tramway afghanistan airplane airplane_arrival airplane_arriving airplane_departure airplane_small
+aland_islands alarm_clock albania alembic algeria alien alien_monster ambulance american_football
+american_samoa amphora anatomical_heart anchor andorra angel angel_tone1 angel_tone2 angel_tone3 angel_tone4
+angel_tone5 anger anger_right anger_symbol angola angry angry_face angry_face_with_horns anguilla anguished
+anguished_face ant antarctica antenna_bars antigua_barbuda anxious_face_with_sweat apple aquarius argentina
+aries armenia arrow_backward arrow_double_down arrow_double_up arrow_down arrow_down_small arrow_forward
+arrow_heading_down arrow_heading_up arrow_left arrow_lower_left arrow_lower_right arrow_right arrow_right_hook
+arrow_up arrow_up_down arrow_up_small arrow_upper_left arrow_upper_right arrows_clockwise
+arrows_counterclockwise art articulated_lorry artificial_satellite artist artist_palette aruba
+ascension_island asterisk astonished astonished_face astronaut athletic_shoe atm atom atom_symbol australia
+austria auto_rickshaw automobile avocado axe azerbaijan b baby baby_angel baby_bottle baby_chick baby_symbol
+baby_tone1 baby_tone2 baby_tone3 baby_tone4 baby_tone5 back backhand_index_pointing_down
+backhand_index_pointing_left backhand_index_pointing_right backhand_index_pointing_up backpack bacon badger
+badminton badminton_racquet_and_shuttlecock bagel baggage_claim baguette_bread bahamas bahrain balance_scale
+bald bald_man bald_person bald_woman ballet_shoes balloon ballot_box ballot_box_with_ballot
+ballot_box_with_check bamboo banana bangbang bangladesh banjo bank bar_chart barbados barber barber_pole
+barely_sunny baseball basket basketball basketball_man basketball_woman bat bath bath_tone1 bath_tone2
+bath_tone3 bath_tone4 bath_tone5 bathtub battery beach beach_umbrella beach_with_umbrella
+beaming_face_with_smiling_eyes beans bear bearded_person bearded_person_tone1 bearded_person_tone2
+bearded_person_tone3 bearded_person_tone4 bearded_person_tone5 beating_heart beaver bed bee beer beer_mug
+beers beetle beginner belarus belgium belize bell bell_pepper bell_with_slash bellhop bellhop_bell benin bento
+bento_box bermuda beverage_box bhutan bicycle bicyclist bike biking_man biking_woman bikini billed_cap
+biohazard biohazard_sign bird birthday birthday_cake bison biting_lip black_bird black_cat black_circle
+black_circle_for_record black_flag black_heart black_joker black_large_square
+black_left_pointing_double_triangle_with_vertical_bar black_medium-small_square black_medium_small_square
+black_medium_square black_nib black_right_pointing_double_triangle_with_vertical_bar
+black_right_pointing_triangle_with_double_vertical_bar black_small_square black_square_button
+black_square_for_stop blond-haired-man blond-haired-woman blond-haired_man blond-haired_man_tone1
+blond-haired_man_tone2 blond-haired_man_tone3 blond-haired_man_tone4 blond-haired_man_tone5 blond-haired_woman
+blond-haired_woman_tone1 blond-haired_woman_tone2 blond-haired_woman_tone3 blond-haired_woman_tone4
+blond-haired_woman_tone5 blond_haired_man blond_haired_person blond_haired_person_tone1
+blond_haired_person_tone2 blond_haired_person_tone3 blond_haired_person_tone4 blond_haired_person_tone5
+blond_haired_woman blonde_woman blossom blowfish blue_book blue_car blue_circle blue_heart blue_square
+blueberries blush boar boat bolivia bomb bone book bookmark bookmark_tabs books boom boomerang boot
+bosnia_herzegovina botswana bottle_with_popping_cork bouncing_ball_man bouncing_ball_person
+bouncing_ball_woman bouquet bouvet_island bow bow_and_arrow bowing_man bowing_woman bowl_with_spoon bowling
+boxing_glove boy boy_tone1 boy_tone2 boy_tone3 boy_tone4 boy_tone5 brain brazil bread breast-feeding
+breast_feeding breast_feeding_tone1 breast_feeding_tone2 breast_feeding_tone3 breast_feeding_tone4
+breast_feeding_tone5 brick bricks bride_with_veil bride_with_veil_tone1 bride_with_veil_tone2
+bride_with_veil_tone3 bride_with_veil_tone4 bride_with_veil_tone5 bridge_at_night briefcase briefs
+bright_button british_indian_ocean_territory british_virgin_islands broccoli broken_chain broken_heart broom
+brown_circle brown_heart brown_mushroom brown_square brunei bubble_tea bubbles bucket bug
+building_construction bulb bulgaria bullet_train bullettrain_front bullettrain_side bullseye burkina_faso
+burrito burundi bus bus_stop business_suit_levitating busstop bust_in_silhouette busts_in_silhouette butter
+butterfly cactus cake calendar calendar_spiral call_me call_me_hand call_me_tone1 call_me_tone2 call_me_tone3
+call_me_tone4 call_me_tone5 calling cambodia camel camera camera_flash camera_with_flash cameroon camping
+canada canary_islands cancer candle candy canned_food canoe cape_verde capital_abcd capricorn car card_box
+card_file_box card_index card_index_dividers caribbean_netherlands carousel_horse carp_streamer carpentry_saw
+carrot cartwheeling castle cat cat2 cat_face cat_with_tears_of_joy cat_with_wry_smile cayman_islands cd
+central_african_republic ceuta_melilla chad chains chair champagne champagne_glass chart chart_decreasing
+chart_increasing chart_increasing_with_yen chart_with_downwards_trend chart_with_upwards_trend
+check_box_with_check check_mark check_mark_button checkered_flag cheese cheese_wedge chequered_flag cherries
+cherry_blossom chess_pawn chestnut chicken child child_tone1 child_tone2 child_tone3 child_tone4 child_tone5
+children_crossing chile chipmunk chocolate_bar chopsticks christmas_island christmas_tree church cigarette
+cinema circus_tent city_dusk city_sunrise city_sunset cityscape cityscape_at_dusk cl clamp clap clap_tone1
+clap_tone2 clap_tone3 clap_tone4 clap_tone5 clapper clapper_board clapping_hands classical_building climbing
+climbing_man climbing_woman clinking_beer_mugs clinking_glasses clipboard clipperton_island clock clock1
+clock10 clock1030 clock11 clock1130 clock12 clock1230 clock130 clock2 clock230 clock3 clock330 clock4 clock430
+clock5 clock530 clock6 clock630 clock7 clock730 clock8 clock830 clock9 clock930 clockwise_vertical_arrows
+closed_book closed_lock_with_key closed_mailbox_with_lowered_flag closed_mailbox_with_raised_flag
+closed_umbrella cloud cloud_lightning cloud_rain cloud_snow cloud_tornado cloud_with_lightning
+cloud_with_lightning_and_rain cloud_with_rain cloud_with_snow clown clown_face club_suit clubs clutch_bag cn
+coat cockroach cocktail cocktail_glass coconut cocos_islands coffee coffin coin cold_face cold_sweat collision
+colombia comet comoros compass compression computer computer_disk computer_mouse confetti_ball confounded
+confounded_face confused confused_face congo_brazzaville congo_kinshasa congratulations construction
+construction_site construction_worker construction_worker_man construction_worker_tone1
+construction_worker_tone2 construction_worker_tone3 construction_worker_tone4 construction_worker_tone5
+construction_worker_woman control_knobs convenience_store cook cook_islands cooked_rice cookie cooking cool
+cop copyright coral corn costa_rica cote_divoire couch couch_and_lamp counterclockwise_arrows_button couple
+couple_mm couple_with_heart couple_with_heart_man_man couple_with_heart_woman_man
+couple_with_heart_woman_woman couple_ww couplekiss couplekiss_man_man couplekiss_man_woman
+couplekiss_woman_woman cow cow2 cow_face cowboy cowboy_hat_face crab crayon crazy_face credit_card
+crescent_moon cricket cricket_bat_and_ball cricket_game croatia crocodile croissant cross cross_mark
+cross_mark_button crossed_fingers crossed_flags crossed_swords crown cruise_ship crutch cry crying_cat
+crying_cat_face crying_face crystal_ball cuba cucumber cup_with_straw cupcake cupid curacao curling_stone
+curly_hair curly_haired_man curly_haired_person curly_haired_woman curly_loop currency_exchange curry
+curry_rice cursing_face custard customs cut_of_meat cyclone cyprus czech_republic dagger dagger_knife dancer
+dancer_tone1 dancer_tone2 dancer_tone3 dancer_tone4 dancer_tone5 dancers dancing_men dancing_women dango
+dark_sunglasses dart dash dashing_away date de deaf_man deaf_person deaf_woman deciduous_tree deer
+delivery_truck denmark department_store derelict_house derelict_house_building desert desert_island desktop
+desktop_computer detective detective_tone1 detective_tone2 detective_tone3 detective_tone4 detective_tone5
+diamond_shape_with_a_dot_inside diamond_suit diamond_with_a_dot diamonds diego_garcia dim_button disappointed
+disappointed_face disappointed_relieved disguised_face divide dividers diving_mask diya_lamp dizzy dizzy_face
+djibouti dna do_not_litter dodo dog dog2 dog_face dollar dollar_banknote dolls dolphin dominica
+dominican_republic donkey door dotted_line_face dotted_six-pointed_star double_curly_loop
+double_exclamation_mark double_vertical_bar doughnut dove dove_of_peace down-left_arrow down-right_arrow
+down_arrow downcast_face_with_sweat downwards_button dragon dragon_face dress dromedary_camel drooling_face
+drop_of_blood droplet drum drum_with_drumsticks duck dumpling dvd e-mail eagle ear ear_of_corn ear_of_rice
+ear_tone1 ear_tone2 ear_tone3 ear_tone4 ear_tone5 ear_with_hearing_aid earth_africa earth_americas earth_asia
+ecuador egg eggplant egypt eight eight-pointed_star eight-spoked_asterisk eight-thirty
+eight_pointed_black_star eight_spoked_asterisk eject eject_button el_salvador electric_plug elephant elevator
+eleven-thirty elf elf_man elf_tone1 elf_tone2 elf_tone3 elf_tone4 elf_tone5 elf_woman email empty_nest end
+england enraged_face envelope envelope_with_arrow equatorial_guinea eritrea es estonia ethiopia eu euro
+euro_banknote european_castle european_post_office european_union evergreen_tree ewe exclamation
+exclamation_question_mark exploding_head expressionless expressionless_face eye eye-in-speech-bubble
+eye_in_speech_bubble eye_speech_bubble eyeglasses eyes face_blowing_a_kiss face_exhaling
+face_holding_back_tears face_in_clouds face_palm face_savoring_food face_screaming_in_fear face_vomiting
+face_with_cowboy_hat face_with_crossed-out_eyes face_with_diagonal_mouth face_with_hand_over_mouth
+face_with_head-bandage face_with_head_bandage face_with_medical_mask face_with_monocle
+face_with_open_eyes_and_hand_over_mouth face_with_open_mouth face_with_peeking_eye face_with_raised_eyebrow
+face_with_rolling_eyes face_with_spiral_eyes face_with_steam_from_nose face_with_symbols_on_mouth
+face_with_symbols_over_mouth face_with_tears_of_joy face_with_thermometer face_with_tongue face_without_mouth
+facepalm facepunch factory factory_worker fairy fairy_man fairy_tone1 fairy_tone2 fairy_tone3 fairy_tone4
+fairy_tone5 fairy_woman falafel falkland_islands fallen_leaf family family_adult_adult_child
+family_adult_adult_child_child family_adult_child family_adult_child_child family_man_boy family_man_boy_boy
+family_man_girl family_man_girl_boy family_man_girl_girl family_man_man_boy family_man_man_boy_boy
+family_man_man_girl family_man_man_girl_boy family_man_man_girl_girl family_man_woman_boy
+family_man_woman_boy_boy family_man_woman_girl family_man_woman_girl_boy family_man_woman_girl_girl family_mmb
+family_mmbb family_mmg family_mmgb family_mmgg family_mwbb family_mwg family_mwgb family_mwgg family_woman_boy
+family_woman_boy_boy family_woman_girl family_woman_girl_boy family_woman_girl_girl family_woman_woman_boy
+family_woman_woman_boy_boy family_woman_woman_girl family_woman_woman_girl_boy family_woman_woman_girl_girl
+family_wwb family_wwbb family_wwg family_wwgb family_wwgg farmer faroe_islands fast-forward_button
+fast_down_button fast_forward fast_reverse_button fast_up_button fax fax_machine fearful fearful_face feather
+feet female-artist female-astronaut female-construction-worker female-cook female-detective female-doctor
+female-factory-worker female-farmer female-firefighter female-guard female-judge female-mechanic
+female-office-worker female-pilot female-police-officer female-scientist female-singer female-student
+female-teacher female-technologist female_detective female_elf female_fairy female_genie female_mage
+female_sign female_superhero female_supervillain female_vampire female_zombie fencer ferris_wheel ferry
+field_hockey field_hockey_stick_and_ball fiji file_cabinet file_folder film_frames film_projector film_strip
+fingers_crossed fingers_crossed_tone1 fingers_crossed_tone2 fingers_crossed_tone3 fingers_crossed_tone4
+fingers_crossed_tone5 finland fire fire_engine fire_extinguisher firecracker firefighter fireworks first_place
+first_place_medal first_quarter_moon first_quarter_moon_face first_quarter_moon_with_face fish fish_cake
+fish_cake_with_swirl fishing_pole fishing_pole_and_fish fist fist_left fist_oncoming fist_raised fist_right
+fist_tone1 fist_tone2 fist_tone3 fist_tone4 fist_tone5 five five-thirty flag-ac flag-ad flag-ae flag-af
+flag-ag flag-ai flag-al flag-am flag-ao flag-aq flag-ar flag-as flag-at flag-au flag-aw flag-ax flag-az
+flag-ba flag-bb flag-bd flag-be flag-bf flag-bg flag-bh flag-bi flag-bj flag-bl flag-bm flag-bn flag-bo
+flag-bq flag-br flag-bs flag-bt flag-bv flag-bw flag-by flag-bz flag-ca flag-cc flag-cd flag-cf flag-cg
+flag-ch flag-ci flag-ck flag-cl flag-cm flag-co flag-cp flag-cr flag-cu flag-cv flag-cw flag-cx flag-cy
+flag-cz flag-dg flag-dj flag-dk flag-dm flag-do flag-dz flag-ea flag-ec flag-ee flag-eg flag-eh flag-england
+flag-er flag-et flag-eu flag-fi flag-fj flag-fk flag-fm flag-fo flag-ga flag-gd flag-ge flag-gf flag-gg
+flag-gh flag-gi flag-gl flag-gm flag-gn flag-gp flag-gq flag-gr flag-gs flag-gt flag-gu flag-gw flag-gy
+flag-hk flag-hm flag-hn flag-hr flag-ht flag-hu flag-ic flag-id flag-ie flag-il flag-im flag-in flag-io
+flag-iq flag-ir flag-is flag-je flag-jm flag-jo flag-ke flag-kg flag-kh flag-ki flag-km flag-kn flag-kp
+flag-kw flag-ky flag-kz flag-la flag-lb flag-lc flag-li flag-lk flag-lr flag-ls flag-lt flag-lu flag-lv
+flag-ly flag-ma flag-mc flag-md flag-me flag-mf flag-mg flag-mh flag-mk flag-ml flag-mm flag-mn flag-mo
+flag-mp flag-mq flag-mr flag-ms flag-mt flag-mu flag-mv flag-mw flag-mx flag-my flag-mz flag-na flag-nc
+flag-ne flag-nf flag-ng flag-ni flag-nl flag-no flag-np flag-nr flag-nu flag-nz flag-om flag-pa flag-pe
+flag-pf flag-pg flag-ph flag-pk flag-pl flag-pm flag-pn flag-pr flag-ps flag-pt flag-pw flag-py flag-qa
+flag-re flag-ro flag-rs flag-rw flag-sa flag-sb flag-sc flag-scotland flag-sd flag-se flag-sg flag-sh flag-si
+flag-sj flag-sk flag-sl flag-sm flag-sn flag-so flag-sr flag-ss flag-st flag-sv flag-sx flag-sy flag-sz
+flag-ta flag-tc flag-td flag-tf flag-tg flag-th flag-tj flag-tk flag-tl flag-tm flag-tn flag-to flag-tr
+flag-tt flag-tv flag-tw flag-tz flag-ua flag-ug flag-um flag-un flag-uy flag-uz flag-va flag-vc flag-ve
+flag-vg flag-vi flag-vn flag-vu flag-wales flag-wf flag-ws flag-xk flag-ye flag-yt flag-za flag-zm flag-zw
+flag_ac flag_ad flag_ae flag_af flag_ag flag_ai flag_al flag_am flag_ao flag_aq flag_ar flag_as flag_at
+flag_au flag_aw flag_ax flag_az flag_ba flag_bb flag_bd flag_be flag_bf flag_bg flag_bh flag_bi flag_bj
+flag_bl flag_black flag_bm flag_bn flag_bo flag_bq flag_br flag_bs flag_bt flag_bv flag_bw flag_by flag_bz
+flag_ca flag_cc flag_cd flag_cf flag_cg flag_ch flag_ci flag_ck flag_cl flag_cm flag_cn flag_co flag_cp
+flag_cr flag_cu flag_cv flag_cw flag_cx flag_cy flag_cz flag_de flag_dg flag_dj flag_dk flag_dm flag_do
+flag_dz flag_ea flag_ec flag_ee flag_eg flag_eh flag_er flag_es flag_et flag_eu flag_fi flag_fj flag_fk
+flag_fm flag_fo flag_fr flag_ga flag_gb flag_gd flag_ge flag_gf flag_gg flag_gh flag_gi flag_gl flag_gm
+flag_gn flag_gp flag_gq flag_gr flag_gs flag_gt flag_gu flag_gw flag_gy flag_hk flag_hm flag_hn flag_hr
+flag_ht flag_hu flag_ic flag_id flag_ie flag_il flag_im flag_in flag_in_hole flag_io flag_iq flag_ir flag_is
+flag_it flag_je flag_jm flag_jo flag_jp flag_ke flag_kg flag_kh flag_ki flag_km flag_kn flag_kp flag_kr
+flag_kw flag_ky flag_kz flag_la flag_lb flag_lc flag_li flag_lk flag_lr flag_ls flag_lt flag_lu flag_lv
+flag_ly flag_ma flag_mc flag_md flag_me flag_mf flag_mg flag_mh flag_mk flag_ml flag_mm flag_mn flag_mo
+flag_mp flag_mq flag_mr flag_ms flag_mt flag_mu flag_mv flag_mw flag_mx flag_my flag_mz flag_na flag_nc
+flag_ne flag_nf flag_ng flag_ni flag_nl flag_no flag_np flag_nr flag_nu flag_nz flag_om flag_pa flag_pe
+flag_pf flag_pg flag_ph flag_pk flag_pl flag_pm flag_pn flag_pr flag_ps flag_pt flag_pw flag_py flag_qa
+flag_re flag_ro flag_rs flag_ru flag_rw flag_sa flag_sb flag_sc flag_sd flag_se flag_sg flag_sh flag_si
+flag_sj flag_sk flag_sl flag_sm flag_sn flag_so flag_sr flag_ss flag_st flag_sv flag_sx flag_sy flag_sz
+flag_ta flag_tc flag_td flag_tf flag_tg flag_th flag_tj flag_tk flag_tl flag_tm flag_tn flag_to flag_tr
+flag_tt flag_tv flag_tw flag_tz flag_ua flag_ug flag_um flag_us flag_uy flag_uz flag_va flag_vc flag_ve
+flag_vg flag_vi flag_vn flag_vu flag_wf flag_white flag_ws flag_xk flag_ye flag_yt flag_za flag_zm flag_zw
+flags flamingo flashlight flat_shoe flatbread fleur-de-lis fleur_de_lis flexed_biceps flight_arrival
+flight_departure flipper floppy_disk flower_playing_cards flushed flushed_face flute fly flying_disc
+flying_saucer fog foggy folded_hands folding_hand_fan fondue foot football footprints fork_and_knife
+fork_and_knife_with_plate fork_knife_plate fortune_cookie fountain fountain_pen four four-thirty
+four_leaf_clover fox fox_face fr frame_photo frame_with_picture framed_picture free french_bread french_fries
+french_guiana french_polynesia french_southern_territories fried_egg fried_shrimp fries frog
+front-facing_baby_chick frowning frowning2 frowning_face frowning_face_with_open_mouth frowning_man
+frowning_person frowning_woman fu fuel_pump fuelpump full_moon full_moon_face full_moon_with_face funeral_urn
+gabon gambia game_die garlic gb gear gem gem_stone gemini genie genie_man genie_woman georgia ghana ghost
+gibraltar gift gift_heart ginger_root giraffe giraffe_face girl girl_tone1 girl_tone2 girl_tone3 girl_tone4
+girl_tone5 glass_of_milk glasses globe_with_meridians gloves glowing_star goal goal_net goat goblin goggles
+golf golfer golfing golfing_man golfing_woman goose gorilla graduation_cap grapes greece green_apple
+green_book green_circle green_heart green_salad green_square greenland grenada grey_exclamation grey_heart
+grey_question grimacing grimacing_face grin grinning grinning_cat grinning_cat_with_smiling_eyes grinning_face
+grinning_face_with_big_eyes grinning_face_with_smiling_eyes grinning_face_with_sweat grinning_squinting_face
+growing_heart guadeloupe guam guard guard_tone1 guard_tone2 guard_tone3 guard_tone4 guard_tone5 guardsman
+guardswoman guatemala guernsey guide_dog guinea guinea_bissau guitar gun guyana hair_pick haircut haircut_man
+haircut_woman haiti hamburger hammer hammer_and_pick hammer_and_wrench hammer_pick hamsa hamster hand
+hand_over_mouth hand_splayed_tone1 hand_splayed_tone2 hand_splayed_tone3 hand_splayed_tone4 hand_splayed_tone5
+hand_with_fingers_splayed hand_with_index_finger_and_thumb_crossed handbag handball handball_person handshake
+hankey hash hatched_chick hatching_chick head_bandage head_shaking_horizontally head_shaking_vertically
+headphone headphones headstone health_worker hear-no-evil_monkey hear_no_evil heard_mcdonald_islands heart
+heart_decoration heart_exclamation heart_eyes heart_eyes_cat heart_hands heart_on_fire heart_suit
+heart_with_arrow heart_with_ribbon heartbeat heartpulse hearts heavy_check_mark heavy_division_sign
+heavy_dollar_sign heavy_equals_sign heavy_exclamation_mark heavy_heart_exclamation
+heavy_heart_exclamation_mark_ornament heavy_minus_sign heavy_multiplication_x heavy_plus_sign hedgehog
+helicopter helmet_with_cross helmet_with_white_cross herb hibiscus high-heeled_shoe high-speed_train
+high_brightness high_heel high_voltage hiking_boot hindu_temple hippopotamus hocho hockey hole
+hollow_red_circle homes honduras honey_pot honeybee hong_kong hook horizontal_traffic_light horse horse_face
+horse_racing horse_racing_tone1 horse_racing_tone2 horse_racing_tone3 horse_racing_tone4 horse_racing_tone5
+hospital hot_beverage hot_dog hot_face hot_pepper hot_springs hotdog hotel hotsprings hourglass hourglass_done
+hourglass_flowing_sand hourglass_not_done house house_abandoned house_buildings house_with_garden houses
+hugging hugging_face hugs hundred_points hungary hushed hushed_face hut hyacinth i_love_you_hand_sign ice
+ice_cream ice_cube ice_hockey ice_hockey_stick_and_puck ice_skate icecream iceland id identification_card
+ideograph_advantage imp inbox_tray incoming_envelope index_pointing_at_the_viewer index_pointing_up india
+indonesia infinity information information_desk_person information_source innocent input_latin_letters
+input_latin_lowercase input_latin_uppercase input_numbers input_symbols interrobang iphone iran iraq ireland
+island isle_of_man israel it izakaya_lantern jack-o-lantern jack_o_lantern jamaica japan japanese_castle
+japanese_goblin japanese_ogre jar jeans jellyfish jersey jigsaw joker jordan joy joy_cat joystick jp judge
+juggling juggling_person kaaba kangaroo kazakhstan kenya key key2 keyboard keycap_0 keycap_1 keycap_10
+keycap_2 keycap_3 keycap_4 keycap_5 keycap_6 keycap_7 keycap_8 keycap_9 keycap_star keycap_ten khanda
+kick_scooter kimono kiribati kiss kiss_man_man kiss_mark kiss_mm kiss_woman_man kiss_woman_woman kiss_ww
+kissing kissing_cat kissing_closed_eyes kissing_face kissing_face_with_closed_eyes
+kissing_face_with_smiling_eyes kissing_heart kissing_smiling_eyes kitchen_knife kite kiwi kiwi_fruit kiwifruit
+kneeling_man kneeling_person kneeling_woman knife knife_fork_plate knot koala koko kosovo kr kuwait kyrgyzstan
+lab_coat label lacrosse ladder lady_beetle ladybug lantern laos laptop large_blue_circle large_blue_diamond
+large_blue_square large_brown_circle large_brown_square large_green_circle large_green_square
+large_orange_circle large_orange_diamond large_orange_square large_purple_circle large_purple_square
+large_red_square large_yellow_circle large_yellow_square last_quarter_moon last_quarter_moon_face
+last_quarter_moon_with_face last_track_button latin_cross latvia laughing leaf_fluttering_in_wind leafy_green
+leaves lebanon ledger left-facing_fist left-right_arrow left_arrow left_arrow_curving_right left_facing_fist
+left_facing_fist_tone1 left_facing_fist_tone2 left_facing_fist_tone3 left_facing_fist_tone4
+left_facing_fist_tone5 left_luggage left_right_arrow left_speech_bubble leftwards_arrow_with_hook
+leftwards_hand leftwards_pushing_hand leg lemon leo leopard lesotho level_slider liberia libra libya
+liechtenstein light_blue_heart light_bulb light_rail lightning lime link linked_paperclips lion lion_face lips
+lipstick lithuania litter_in_bin_sign lizard llama lobster lock lock_with_ink_pen locked locked_with_key
+locked_with_pen locomotive lollipop long_drum loop lotion_bottle lotus lotus_position lotus_position_man
+lotus_position_woman loud_sound loudly_crying_face loudspeaker love-you_gesture love_hotel love_letter
+love_you_gesture love_you_gesture_tone1 love_you_gesture_tone2 love_you_gesture_tone3 love_you_gesture_tone4
+love_you_gesture_tone5 low_battery low_brightness lower_left_ballpoint_pen lower_left_crayon
+lower_left_fountain_pen lower_left_paintbrush luggage lungs luxembourg lying_face m macau macedonia madagascar
+mag mag_right mage mage_man mage_tone1 mage_tone2 mage_tone3 mage_tone4 mage_tone5 mage_woman magic_wand
+magnet magnifying_glass_tilted_left magnifying_glass_tilted_right mahjong mahjong_red_dragon mailbox
+mailbox_closed mailbox_with_mail mailbox_with_no_mail malawi malaysia maldives male-artist male-astronaut
+male-construction-worker male-cook male-detective male-doctor male-factory-worker male-farmer male-firefighter
+male-guard male-judge male-mechanic male-office-worker male-pilot male-police-officer male-scientist
+male-singer male-student male-teacher male-technologist male_detective male_elf male_fairy male_genie
+male_mage male_sign male_superhero male_supervillain male_vampire male_zombie mali malta mammoth man
+man-biking man-bouncing-ball man-bowing man-boy man-boy-boy man-cartwheeling man-facepalming man-frowning
+man-gesturing-no man-gesturing-ok man-getting-haircut man-getting-massage man-girl man-girl-boy man-girl-girl
+man-golfing man-heart-man man-juggling man-kiss-man man-lifting-weights man-man-boy man-man-boy-boy
+man-man-girl man-man-girl-boy man-man-girl-girl man-mountain-biking man-playing-handball
+man-playing-water-polo man-pouting man-raising-hand man-rowing-boat man-running man-shrugging man-surfing
+man-swimming man-tipping-hand man-walking man-wearing-turban man-woman-boy man-woman-boy-boy man-woman-girl
+man-woman-girl-boy man-woman-girl-girl man-wrestling man_and_woman_holding_hands man_artist man_artist_tone1
+man_artist_tone2 man_artist_tone3 man_artist_tone4 man_artist_tone5 man_astronaut man_astronaut_tone1
+man_astronaut_tone2 man_astronaut_tone3 man_astronaut_tone4 man_astronaut_tone5 man_bald man_beard man_biking
+man_biking_tone1 man_biking_tone2 man_biking_tone3 man_biking_tone4 man_biking_tone5 man_blond_hair
+man_bouncing_ball man_bouncing_ball_tone1 man_bouncing_ball_tone2 man_bouncing_ball_tone3
+man_bouncing_ball_tone4 man_bouncing_ball_tone5 man_bowing man_bowing_tone1 man_bowing_tone2 man_bowing_tone3
+man_bowing_tone4 man_bowing_tone5 man_cartwheeling man_cartwheeling_tone1 man_cartwheeling_tone2
+man_cartwheeling_tone3 man_cartwheeling_tone4 man_cartwheeling_tone5 man_climbing man_climbing_tone1
+man_climbing_tone2 man_climbing_tone3 man_climbing_tone4 man_climbing_tone5 man_construction_worker
+man_construction_worker_tone1 man_construction_worker_tone2 man_construction_worker_tone3
+man_construction_worker_tone4 man_construction_worker_tone5 man_cook man_cook_tone1 man_cook_tone2
+man_cook_tone3 man_cook_tone4 man_cook_tone5 man_curly_hair man_dancing man_dancing_tone1 man_dancing_tone2
+man_dancing_tone3 man_dancing_tone4 man_dancing_tone5 man_detective man_detective_tone1 man_detective_tone2
+man_detective_tone3 man_detective_tone4 man_detective_tone5 man_elf man_elf_tone1 man_elf_tone2 man_elf_tone3
+man_elf_tone4 man_elf_tone5 man_facepalming man_facepalming_tone1 man_facepalming_tone2 man_facepalming_tone3
+man_facepalming_tone4 man_facepalming_tone5 man_factory_worker man_factory_worker_tone1
+man_factory_worker_tone2 man_factory_worker_tone3 man_factory_worker_tone4 man_factory_worker_tone5 man_fairy
+man_fairy_tone1 man_fairy_tone2 man_fairy_tone3 man_fairy_tone4 man_fairy_tone5 man_farmer man_farmer_tone1
+man_farmer_tone2 man_farmer_tone3 man_farmer_tone4 man_farmer_tone5 man_feeding_baby man_firefighter
+man_firefighter_tone1 man_firefighter_tone2 man_firefighter_tone3 man_firefighter_tone4 man_firefighter_tone5
+man_frowning man_frowning_tone1 man_frowning_tone2 man_frowning_tone3 man_frowning_tone4 man_frowning_tone5
+man_genie man_gesturing_no man_gesturing_no_tone1 man_gesturing_no_tone2 man_gesturing_no_tone3
+man_gesturing_no_tone4 man_gesturing_no_tone5 man_gesturing_ok man_gesturing_ok_tone1 man_gesturing_ok_tone2
+man_gesturing_ok_tone3 man_gesturing_ok_tone4 man_gesturing_ok_tone5 man_getting_face_massage
+man_getting_face_massage_tone1 man_getting_face_massage_tone2 man_getting_face_massage_tone3
+man_getting_face_massage_tone4 man_getting_face_massage_tone5 man_getting_haircut man_getting_haircut_tone1
+man_getting_haircut_tone2 man_getting_haircut_tone3 man_getting_haircut_tone4 man_getting_haircut_tone5
+man_getting_massage man_golfing man_golfing_tone1 man_golfing_tone2 man_golfing_tone3 man_golfing_tone4
+man_golfing_tone5 man_guard man_guard_tone1 man_guard_tone2 man_guard_tone3 man_guard_tone4 man_guard_tone5
+man_health_worker man_health_worker_tone1 man_health_worker_tone2 man_health_worker_tone3
+man_health_worker_tone4 man_health_worker_tone5 man_in_business_suit_levitating
+man_in_business_suit_levitating_tone1 man_in_business_suit_levitating_tone2
+man_in_business_suit_levitating_tone3 man_in_business_suit_levitating_tone4
+man_in_business_suit_levitating_tone5 man_in_lotus_position man_in_lotus_position_tone1
+man_in_lotus_position_tone2 man_in_lotus_position_tone3 man_in_lotus_position_tone4
+man_in_lotus_position_tone5 man_in_manual_wheelchair man_in_manual_wheelchair_facing_right
+man_in_motorized_wheelchair man_in_motorized_wheelchair_facing_right man_in_steamy_room
+man_in_steamy_room_tone1 man_in_steamy_room_tone2 man_in_steamy_room_tone3 man_in_steamy_room_tone4
+man_in_steamy_room_tone5 man_in_tuxedo man_in_tuxedo_tone1 man_in_tuxedo_tone2 man_in_tuxedo_tone3
+man_in_tuxedo_tone4 man_in_tuxedo_tone5 man_judge man_judge_tone1 man_judge_tone2 man_judge_tone3
+man_judge_tone4 man_judge_tone5 man_juggling man_juggling_tone1 man_juggling_tone2 man_juggling_tone3
+man_juggling_tone4 man_juggling_tone5 man_kneeling man_kneeling_facing_right man_lifting_weights
+man_lifting_weights_tone1 man_lifting_weights_tone2 man_lifting_weights_tone3 man_lifting_weights_tone4
+man_lifting_weights_tone5 man_mage man_mage_tone1 man_mage_tone2 man_mage_tone3 man_mage_tone4 man_mage_tone5
+man_mechanic man_mechanic_tone1 man_mechanic_tone2 man_mechanic_tone3 man_mechanic_tone4 man_mechanic_tone5
+man_mountain_biking man_mountain_biking_tone1 man_mountain_biking_tone2 man_mountain_biking_tone3
+man_mountain_biking_tone4 man_mountain_biking_tone5 man_office_worker man_office_worker_tone1
+man_office_worker_tone2 man_office_worker_tone3 man_office_worker_tone4 man_office_worker_tone5 man_pilot
+man_pilot_tone1 man_pilot_tone2 man_pilot_tone3 man_pilot_tone4 man_pilot_tone5 man_playing_handball
+man_playing_handball_tone1 man_playing_handball_tone2 man_playing_handball_tone3 man_playing_handball_tone4
+man_playing_handball_tone5 man_playing_water_polo man_playing_water_polo_tone1 man_playing_water_polo_tone2
+man_playing_water_polo_tone3 man_playing_water_polo_tone4 man_playing_water_polo_tone5 man_police_officer
+man_police_officer_tone1 man_police_officer_tone2 man_police_officer_tone3 man_police_officer_tone4
+man_police_officer_tone5 man_pouting man_pouting_tone1 man_pouting_tone2 man_pouting_tone3 man_pouting_tone4
+man_pouting_tone5 man_raising_hand man_raising_hand_tone1 man_raising_hand_tone2 man_raising_hand_tone3
+man_raising_hand_tone4 man_raising_hand_tone5 man_red_hair man_rowing_boat man_rowing_boat_tone1
+man_rowing_boat_tone2 man_rowing_boat_tone3 man_rowing_boat_tone4 man_rowing_boat_tone5 man_running
+man_running_facing_right man_running_tone1 man_running_tone2 man_running_tone3 man_running_tone4
+man_running_tone5 man_scientist man_scientist_tone1 man_scientist_tone2 man_scientist_tone3
+man_scientist_tone4 man_scientist_tone5 man_shrugging man_shrugging_tone1 man_shrugging_tone2
+man_shrugging_tone3 man_shrugging_tone4 man_shrugging_tone5 man_singer man_singer_tone1 man_singer_tone2
+man_singer_tone3 man_singer_tone4 man_singer_tone5 man_standing man_student man_student_tone1
+man_student_tone2 man_student_tone3 man_student_tone4 man_student_tone5 man_superhero man_supervillain
+man_surfing man_surfing_tone1 man_surfing_tone2 man_surfing_tone3 man_surfing_tone4 man_surfing_tone5
+man_swimming man_swimming_tone1 man_swimming_tone2 man_swimming_tone3 man_swimming_tone4 man_swimming_tone5
+man_teacher man_teacher_tone1 man_teacher_tone2 man_teacher_tone3 man_teacher_tone4 man_teacher_tone5
+man_technologist man_technologist_tone1 man_technologist_tone2 man_technologist_tone3 man_technologist_tone4
+man_technologist_tone5 man_tipping_hand man_tipping_hand_tone1 man_tipping_hand_tone2 man_tipping_hand_tone3
+man_tipping_hand_tone4 man_tipping_hand_tone5 man_tone1 man_tone2 man_tone3 man_tone4 man_tone5 man_vampire
+man_vampire_tone1 man_vampire_tone2 man_vampire_tone3 man_vampire_tone4 man_vampire_tone5 man_walking
+man_walking_facing_right man_walking_tone1 man_walking_tone2 man_walking_tone3 man_walking_tone4
+man_walking_tone5 man_wearing_turban man_wearing_turban_tone1 man_wearing_turban_tone2
+man_wearing_turban_tone3 man_wearing_turban_tone4 man_wearing_turban_tone5 man_white_hair man_with_beard
+man_with_chinese_cap man_with_chinese_cap_tone1 man_with_chinese_cap_tone2 man_with_chinese_cap_tone3
+man_with_chinese_cap_tone4 man_with_chinese_cap_tone5 man_with_gua_pi_mao man_with_probing_cane
+man_with_turban man_with_veil man_with_white_cane man_with_white_cane_facing_right man_zombie mandarin mango
+mans_shoe mantelpiece_clock manual_wheelchair map maple_leaf maracas marshall_islands martial_arts_uniform
+martinique mask massage massage_man massage_woman mate mate_drink mauritania mauritius mayotte meat_on_bone
+mechanic mechanical_arm mechanical_leg medal medal_military medal_sports medical_symbol mega megaphone melon
+melting_face memo men-with-bunny-ears-partying men_holding_hands men_with_bunny_ears
+men_with_bunny_ears_partying men_wrestling mending_heart menorah menorah_with_nine_branches mens mermaid
+mermaid_tone1 mermaid_tone2 mermaid_tone3 mermaid_tone4 mermaid_tone5 merman merman_tone1 merman_tone2
+merman_tone3 merman_tone4 merman_tone5 merperson merperson_tone1 merperson_tone2 merperson_tone3
+merperson_tone4 merperson_tone5 metal metal_tone1 metal_tone2 metal_tone3 metal_tone4 metal_tone5 metro mexico
+microbe micronesia microphone microphone2 microscope middle_finger middle_finger_tone1 middle_finger_tone2
+middle_finger_tone3 middle_finger_tone4 middle_finger_tone5 military_helmet military_medal milk milk_glass
+milky_way minibus minidisc minus mirror mirror_ball moai mobile_phone mobile_phone_off mobile_phone_with_arrow
+moldova monaco money-mouth_face money_bag money_mouth money_mouth_face money_with_wings moneybag mongolia
+monkey monkey_face monocle_face monorail montenegro montserrat moon moon_cake moon_viewing_ceremony moose
+morocco mortar_board mosque mosquito mostly_sunny motor_boat motor_scooter motorboat motorcycle
+motorized_wheelchair motorway mount_fuji mountain mountain_bicyclist mountain_biking_man mountain_biking_woman
+mountain_cableway mountain_railway mountain_snow mouse mouse2 mouse_face mouse_three_button mouse_trap mouth
+movie_camera moyai mozambique mrs_claus mrs_claus_tone1 mrs_claus_tone2 mrs_claus_tone3 mrs_claus_tone4
+mrs_claus_tone5 multiply muscle muscle_tone1 muscle_tone2 muscle_tone3 muscle_tone4 muscle_tone5 mushroom
+musical_keyboard musical_note musical_notes musical_score mute muted_speaker mx_claus myanmar nail_care
+nail_care_tone1 nail_care_tone2 nail_care_tone3 nail_care_tone4 nail_care_tone5 nail_polish name_badge namibia
+national_park nauru nauseated_face nazar_amulet necktie negative_squared_cross_mark nepal nerd nerd_face
+nest_with_eggs nesting_dolls netherlands neutral_face new new_caledonia new_moon new_moon_face
+new_moon_with_face new_zealand newspaper newspaper2 newspaper_roll next_track_button ng ng_man ng_woman
+nicaragua niger nigeria night_with_stars nine nine-thirty ninja niue no_bell no_bicycles no_entry
+no_entry_sign no_good no_good_man no_good_woman no_littering no_mobile_phones no_mouth no_one_under_eighteen
+no_pedestrians no_smoking non-potable_water norfolk_island north_korea northern_mariana_islands norway nose
+nose_tone1 nose_tone2 nose_tone3 nose_tone4 nose_tone5 notebook notebook_with_decorative_cover notepad_spiral
+notes nut_and_bolt o o2 ocean octagonal_sign octopus oden office office_building office_worker ogre oil
+oil_drum ok ok_hand ok_hand_tone1 ok_hand_tone2 ok_hand_tone3 ok_hand_tone4 ok_hand_tone5 ok_man ok_person
+ok_woman old_key old_man old_woman older_adult older_adult_tone1 older_adult_tone2 older_adult_tone3
+older_adult_tone4 older_adult_tone5 older_man older_man_tone1 older_man_tone2 older_man_tone3 older_man_tone4
+older_man_tone5 older_person older_woman older_woman_tone1 older_woman_tone2 older_woman_tone3
+older_woman_tone4 older_woman_tone5 olive om om_symbol oman on oncoming_automobile oncoming_bus oncoming_fist
+oncoming_police_car oncoming_taxi one one-piece_swimsuit one-thirty one_piece_swimsuit onion open_book
+open_file_folder open_hands open_hands_tone1 open_hands_tone2 open_hands_tone3 open_hands_tone4
+open_hands_tone5 open_mailbox_with_lowered_flag open_mailbox_with_raised_flag open_mouth open_umbrella
+ophiuchus optical_disk orange orange_book orange_circle orange_heart orange_square orangutan orthodox_cross
+otter outbox_tray owl ox oyster package page_facing_up page_with_curl pager paintbrush pakistan palau
+palestinian_territories palm_down_hand palm_tree palm_up_hand palms_up_together palms_up_together_tone1
+palms_up_together_tone2 palms_up_together_tone3 palms_up_together_tone4 palms_up_together_tone5 panama
+pancakes panda panda_face paperclip paperclips papua_new_guinea parachute paraguay parasol_on_ground park
+parking parrot part_alternation_mark partly_sunny partly_sunny_rain party_popper partying_face passenger_ship
+passport_control pause_button paw_prints pea_pod peace peace_symbol peach peacock peanuts pear pen
+pen_ballpoint pen_fountain pencil pencil2 penguin pensive pensive_face people_holding_hands people_hugging
+people_with_bunny_ears people_with_bunny_ears_partying people_wrestling performing_arts persevere
+persevering_face person person_bald person_beard person_biking person_biking_tone1 person_biking_tone2
+person_biking_tone3 person_biking_tone4 person_biking_tone5 person_blond_hair person_bouncing_ball
+person_bouncing_ball_tone1 person_bouncing_ball_tone2 person_bouncing_ball_tone3 person_bouncing_ball_tone4
+person_bouncing_ball_tone5 person_bowing person_bowing_tone1 person_bowing_tone2 person_bowing_tone3
+person_bowing_tone4 person_bowing_tone5 person_cartwheeling person_climbing person_climbing_tone1
+person_climbing_tone2 person_climbing_tone3 person_climbing_tone4 person_climbing_tone5 person_curly_hair
+person_doing_cartwheel person_doing_cartwheel_tone1 person_doing_cartwheel_tone2 person_doing_cartwheel_tone3
+person_doing_cartwheel_tone4 person_doing_cartwheel_tone5 person_facepalming person_facepalming_tone1
+person_facepalming_tone2 person_facepalming_tone3 person_facepalming_tone4 person_facepalming_tone5
+person_feeding_baby person_fencing person_frowning person_frowning_tone1 person_frowning_tone2
+person_frowning_tone3 person_frowning_tone4 person_frowning_tone5 person_gesturing_no
+person_gesturing_no_tone1 person_gesturing_no_tone2 person_gesturing_no_tone3 person_gesturing_no_tone4
+person_gesturing_no_tone5 person_gesturing_ok person_gesturing_ok_tone1 person_gesturing_ok_tone2
+person_gesturing_ok_tone3 person_gesturing_ok_tone4 person_gesturing_ok_tone5 person_getting_haircut
+person_getting_haircut_tone1 person_getting_haircut_tone2 person_getting_haircut_tone3
+person_getting_haircut_tone4 person_getting_haircut_tone5 person_getting_massage person_getting_massage_tone1
+person_getting_massage_tone2 person_getting_massage_tone3 person_getting_massage_tone4
+person_getting_massage_tone5 person_golfing person_golfing_tone1 person_golfing_tone2 person_golfing_tone3
+person_golfing_tone4 person_golfing_tone5 person_in_bed person_in_bed_tone1 person_in_bed_tone2
+person_in_bed_tone3 person_in_bed_tone4 person_in_bed_tone5 person_in_lotus_position
+person_in_lotus_position_tone1 person_in_lotus_position_tone2 person_in_lotus_position_tone3
+person_in_lotus_position_tone4 person_in_lotus_position_tone5 person_in_manual_wheelchair
+person_in_manual_wheelchair_facing_right person_in_motorized_wheelchair
+person_in_motorized_wheelchair_facing_right person_in_steamy_room person_in_steamy_room_tone1
+person_in_steamy_room_tone2 person_in_steamy_room_tone3 person_in_steamy_room_tone4
+person_in_steamy_room_tone5 person_in_suit_levitating person_in_tuxedo person_juggling person_juggling_tone1
+person_juggling_tone2 person_juggling_tone3 person_juggling_tone4 person_juggling_tone5 person_kneeling
+person_kneeling_facing_right person_lifting_weights person_lifting_weights_tone1 person_lifting_weights_tone2
+person_lifting_weights_tone3 person_lifting_weights_tone4 person_lifting_weights_tone5 person_mountain_biking
+person_mountain_biking_tone1 person_mountain_biking_tone2 person_mountain_biking_tone3
+person_mountain_biking_tone4 person_mountain_biking_tone5 person_playing_handball
+person_playing_handball_tone1 person_playing_handball_tone2 person_playing_handball_tone3
+person_playing_handball_tone4 person_playing_handball_tone5 person_playing_water_polo
+person_playing_water_polo_tone1 person_playing_water_polo_tone2 person_playing_water_polo_tone3
+person_playing_water_polo_tone4 person_playing_water_polo_tone5 person_pouting person_pouting_tone1
+person_pouting_tone2 person_pouting_tone3 person_pouting_tone4 person_pouting_tone5 person_raising_hand
+person_raising_hand_tone1 person_raising_hand_tone2 person_raising_hand_tone3 person_raising_hand_tone4
+person_raising_hand_tone5 person_red_hair person_rowing_boat person_rowing_boat_tone1 person_rowing_boat_tone2
+person_rowing_boat_tone3 person_rowing_boat_tone4 person_rowing_boat_tone5 person_running
+person_running_facing_right person_running_tone1 person_running_tone2 person_running_tone3
+person_running_tone4 person_running_tone5 person_shrugging person_shrugging_tone1 person_shrugging_tone2
+person_shrugging_tone3 person_shrugging_tone4 person_shrugging_tone5 person_standing person_surfing
+person_surfing_tone1 person_surfing_tone2 person_surfing_tone3 person_surfing_tone4 person_surfing_tone5
+person_swimming person_swimming_tone1 person_swimming_tone2 person_swimming_tone3 person_swimming_tone4
+person_swimming_tone5 person_taking_bath person_tipping_hand person_tipping_hand_tone1
+person_tipping_hand_tone2 person_tipping_hand_tone3 person_tipping_hand_tone4 person_tipping_hand_tone5
+person_walking person_walking_facing_right person_walking_tone1 person_walking_tone2 person_walking_tone3
+person_walking_tone4 person_walking_tone5 person_wearing_turban person_wearing_turban_tone1
+person_wearing_turban_tone2 person_wearing_turban_tone3 person_wearing_turban_tone4
+person_wearing_turban_tone5 person_white_hair person_with_ball person_with_blond_hair person_with_crown
+person_with_headscarf person_with_pouting_face person_with_probing_cane person_with_skullcap
+person_with_turban person_with_veil person_with_white_cane person_with_white_cane_facing_right peru petri_dish
+philippines phoenix phone pick pickup_truck pie pig pig2 pig_face pig_nose pile_of_poo pill pilot pinata
+pinched_fingers pinching_hand pine_decoration pineapple ping_pong pink_heart pirate_flag pisces
+pitcairn_islands pizza placard place_of_worship plate_with_cutlery play_button play_or_pause_button play_pause
+playground_slide pleading_face plunger plus point_down point_down_tone1 point_down_tone2 point_down_tone3
+point_down_tone4 point_down_tone5 point_left point_left_tone1 point_left_tone2 point_left_tone3
+point_left_tone4 point_left_tone5 point_right point_right_tone1 point_right_tone2 point_right_tone3
+point_right_tone4 point_right_tone5 point_up point_up_2 point_up_2_tone1 point_up_2_tone2 point_up_2_tone3
+point_up_2_tone4 point_up_2_tone5 point_up_tone1 point_up_tone2 point_up_tone3 point_up_tone4 point_up_tone5
+poland polar_bear police_car police_car_light police_officer police_officer_tone1 police_officer_tone2
+police_officer_tone3 police_officer_tone4 police_officer_tone5 policeman policewoman poodle pool_8_ball poop
+popcorn portugal post_office postal_horn postbox pot_of_food potable_water potato potted_plant pouch
+poultry_leg pound pound_banknote pouring_liquid pout pouting_cat pouting_face pouting_man pouting_woman pray
+pray_tone1 pray_tone2 pray_tone3 pray_tone4 pray_tone5 prayer_beads pregnant_man pregnant_person
+pregnant_woman pregnant_woman_tone1 pregnant_woman_tone2 pregnant_woman_tone3 pregnant_woman_tone4
+pregnant_woman_tone5 pretzel previous_track_button prince prince_tone1 prince_tone2 prince_tone3 prince_tone4
+prince_tone5 princess princess_tone1 princess_tone2 princess_tone3 princess_tone4 princess_tone5 printer
+probing_cane prohibited projector puerto_rico punch punch_tone1 punch_tone2 punch_tone3 punch_tone4
+punch_tone5 purple_circle purple_heart purple_square purse pushpin put_litter_in_its_place puzzle_piece qatar
+question rabbit rabbit2 rabbit_face raccoon race_car racehorse racing_car racing_motorcycle radio radio_button
+radioactive radioactive_sign rage railway_car railway_track rain_cloud rainbow rainbow-flag rainbow_flag
+raised_back_of_hand raised_back_of_hand_tone1 raised_back_of_hand_tone2 raised_back_of_hand_tone3
+raised_back_of_hand_tone4 raised_back_of_hand_tone5 raised_eyebrow raised_fist raised_hand raised_hand_tone1
+raised_hand_tone2 raised_hand_tone3 raised_hand_tone4 raised_hand_tone5 raised_hand_with_fingers_splayed
+raised_hands raised_hands_tone1 raised_hands_tone2 raised_hands_tone3 raised_hands_tone4 raised_hands_tone5
+raising_hand raising_hand_man raising_hand_woman raising_hands ram ramen rat razor receipt record_button
+recycle recycling_symbol red_apple red_car red_circle red_envelope red_exclamation_mark red_hair
+red_haired_man red_haired_person red_haired_woman red_heart red_paper_lantern red_question_mark red_square
+red_triangle_pointed_down red_triangle_pointed_up regional_indicator_a regional_indicator_b
+regional_indicator_c regional_indicator_d regional_indicator_e regional_indicator_f regional_indicator_g
+regional_indicator_h regional_indicator_i regional_indicator_j regional_indicator_k regional_indicator_l
+regional_indicator_m regional_indicator_n regional_indicator_o regional_indicator_p regional_indicator_q
+regional_indicator_r regional_indicator_s regional_indicator_t regional_indicator_u regional_indicator_v
+regional_indicator_w regional_indicator_x regional_indicator_y regional_indicator_z registered relaxed
+relieved relieved_face reminder_ribbon repeat repeat_button repeat_one repeat_single_button
+rescue_worker_helmet restroom reunion reverse_button revolving_hearts rewind rhino rhinoceros ribbon rice
+rice_ball rice_cracker rice_scene right-facing_fist right_anger_bubble right_arrow right_arrow_curving_down
+right_arrow_curving_left right_arrow_curving_up right_facing_fist right_facing_fist_tone1
+right_facing_fist_tone2 right_facing_fist_tone3 right_facing_fist_tone4 right_facing_fist_tone5
+rightwards_hand rightwards_pushing_hand ring ring_buoy ringed_planet roasted_sweet_potato robot robot_face
+rock rocket rofl roll_eyes roll_of_paper rolled-up_newspaper rolled_up_newspaper roller_coaster roller_skate
+rolling_eyes rolling_on_the_floor_laughing romania rooster rose rosette rotating_light round_pushpin rowboat
+rowing_man rowing_woman ru rugby_football runner running running_man running_shirt running_shirt_with_sash
+running_shoe running_woman rwanda sa sad_but_relieved_face safety_pin safety_vest sagittarius sailboat sake
+salad salt saluting_face samoa san_marino sandal sandwich santa santa_tone1 santa_tone2 santa_tone3
+santa_tone4 santa_tone5 sao_tome_principe sari sassy_man sassy_woman satellite satellite_antenna
+satellite_orbital satisfied saudi_arabia sauna_man sauna_person sauna_woman sauropod saxophone scales scarf
+school school_satchel scientist scissors scooter scorpion scorpius scotland scream scream_cat screwdriver
+scroll seal seat second_place second_place_medal secret see-no-evil_monkey see_no_evil seedling selfie
+selfie_tone1 selfie_tone2 selfie_tone3 selfie_tone4 selfie_tone5 senegal serbia service_dog seven seven-thirty
+sewing_needle seychelles shaking_face shallow_pan_of_food shamrock shark shaved_ice sheaf_of_rice sheep shell
+shield shinto_shrine ship shirt shit shoe shooting_star shopping shopping_bags shopping_cart shopping_trolley
+shortcake shorts shower shrimp shrug shuffle_tracks_button shushing_face sierra_leone sign_of_the_horns
+signal_strength singapore singer sint_maarten six six-thirty six_pointed_star skateboard ski skier skin-tone-2
+skin-tone-3 skin-tone-4 skin-tone-5 skin-tone-6 skis skull skull_and_crossbones skull_crossbones skunk sled
+sleeping sleeping_accommodation sleeping_bed sleeping_face sleepy sleepy_face sleuth_or_spy slight_frown
+slight_smile slightly_frowning_face slightly_smiling_face slot_machine sloth slovakia slovenia small_airplane
+small_blue_diamond small_orange_diamond small_red_triangle small_red_triangle_down smile smile_cat smiley
+smiley_cat smiling_cat_with_heart-eyes smiling_face smiling_face_with_3_hearts smiling_face_with_halo
+smiling_face_with_heart-eyes smiling_face_with_hearts smiling_face_with_horns smiling_face_with_open_hands
+smiling_face_with_smiling_eyes smiling_face_with_sunglasses smiling_face_with_tear
+smiling_face_with_three_hearts smiling_imp smirk smirk_cat smirking_face smoking snail snake sneezing_face
+snow-capped_mountain snow_capped_mountain snow_cloud snowboarder snowboarder_tone1 snowboarder_tone2
+snowboarder_tone3 snowboarder_tone4 snowboarder_tone5 snowflake snowman snowman2 snowman_with_snow
+snowman_without_snow soap sob soccer soccer_ball socks soft_ice_cream softball solomon_islands somalia soon
+sos sound south_africa south_georgia_south_sandwich_islands south_sudan space_invader spade_suit spades
+spaghetti sparkle sparkler sparkles sparkling_heart speak-no-evil_monkey speak_no_evil speaker
+speaker_high_volume speaker_low_volume speaker_medium_volume speaking_head speaking_head_in_silhouette
+speech_balloon speech_left speedboat spider spider_web spiral_calendar spiral_calendar_pad spiral_note_pad
+spiral_notepad spiral_shell spock-hand sponge spoon sport_utility_vehicle sports_medal spouting_whale squid
+squinting_face_with_tongue sri_lanka st_barthelemy st_helena st_kitts_nevis st_lucia st_martin
+st_pierre_miquelon st_vincent_grenadines stadium standing_man standing_person standing_woman star star-struck
+star2 star_and_crescent star_of_david star_struck stars station statue_of_liberty steam_locomotive
+steaming_bowl stethoscope stew stop_button stop_sign stopwatch straight_ruler strawberry stuck_out_tongue
+stuck_out_tongue_closed_eyes stuck_out_tongue_winking_eye student studio_microphone stuffed_flatbread sudan
+sun sun_behind_cloud sun_behind_large_cloud sun_behind_rain_cloud sun_behind_small_cloud sun_with_face
+sunflower sunglasses sunny sunrise sunrise_over_mountains sunset superhero superhero_man superhero_woman
+supervillain supervillain_man supervillain_woman surfer surfing_man surfing_woman suriname sushi
+suspension_railway svalbard_jan_mayen swan swaziland sweat sweat_droplets sweat_drops sweat_smile sweden
+sweet_potato swim_brief swimmer swimming_man swimming_woman switzerland symbols synagogue syria syringe t-rex
+t-shirt t_rex table_tennis_paddle_and_ball taco tada taiwan tajikistan takeout_box tamale tanabata_tree
+tangerine tanzania taurus taxi tea teacher teacup_without_handle teapot tear-off_calendar technologist
+teddy_bear telephone telephone_receiver telescope television ten-thirty tennis tent test_tube thailand
+the_horns thermometer thermometer_face thinking thinking_face third_place third_place_medal thong_sandal
+thought_balloon thread three three-thirty three_button_mouse thumbs_down thumbs_up thumbsdown thumbsdown_tone1
+thumbsdown_tone2 thumbsdown_tone3 thumbsdown_tone4 thumbsdown_tone5 thumbsup thumbsup_tone1 thumbsup_tone2
+thumbsup_tone3 thumbsup_tone4 thumbsup_tone5 thunder_cloud_and_rain thunder_cloud_rain ticket tickets tiger
+tiger2 tiger_face timer timer_clock timor_leste tipping_hand_man tipping_hand_person tipping_hand_woman
+tired_face tm togo toilet tokelau tokyo_tower tomato tonga tongue toolbox tools tooth toothbrush top top_hat
+tophat tornado tr track_next track_previous trackball tractor trade_mark traffic_light train train2 tram
+tram_car transgender_flag transgender_symbol triangular_flag triangular_flag_on_post triangular_ruler trident
+trident_emblem trinidad_tobago tristan_da_cunha triumph troll trolleybus trophy tropical_drink tropical_fish
+truck trumpet tshirt tulip tumbler_glass tunisia turkey turkmenistan turks_caicos_islands turtle tuvalu tv
+twelve-thirty twisted_rightwards_arrows two two-hump_camel two-thirty two_hearts two_men_holding_hands
+two_women_holding_hands u5272 u5408 u55b6 u6307 u6708 u6709 u6e80 u7121 u7533 u7981 u7a7a uganda uk ukraine
+umbrella umbrella2 umbrella_on_ground umbrella_with_rain_drops unamused unamused_face underage unicorn
+unicorn_face united_arab_emirates united_nations unlock unlocked up up-down_arrow up-left_arrow up-right_arrow
+up_arrow upside-down_face upside_down upside_down_face upwards_button urn uruguay us us_outlying_islands
+us_virgin_islands uzbekistan v v_tone1 v_tone2 v_tone3 v_tone4 v_tone5 vampire vampire_man vampire_tone1
+vampire_tone2 vampire_tone3 vampire_tone4 vampire_tone5 vampire_woman vanuatu vatican_city venezuela
+vertical_traffic_light vhs vibration_mode victory_hand video_camera video_game videocassette vietnam violin
+virgo volcano volleyball vomiting_face vs vulcan vulcan_salute vulcan_tone1 vulcan_tone2 vulcan_tone3
+vulcan_tone4 vulcan_tone5 waffle wales walking walking_man walking_woman wallis_futuna waning_crescent_moon
+waning_gibbous_moon warning wastebasket watch water_buffalo water_closet water_pistol water_polo water_wave
+watermelon wave wave_tone1 wave_tone2 wave_tone3 wave_tone4 wave_tone5 waving_black_flag waving_hand
+waving_white_flag wavy_dash waxing_crescent_moon waxing_gibbous_moon wc weary weary_cat weary_face wedding
+weight_lifter weight_lifting weight_lifting_man weight_lifting_woman western_sahara whale whale2 wheel
+wheel_of_dharma wheelchair wheelchair_symbol white_cane white_check_mark white_circle white_exclamation_mark
+white_flag white_flower white_frowning_face white_hair white_haired_man white_haired_person white_haired_woman
+white_heart white_large_square white_medium-small_square white_medium_small_square white_medium_square
+white_question_mark white_small_square white_square_button white_sun_cloud white_sun_rain_cloud
+white_sun_small_cloud wilted_flower wilted_rose wind_blowing_face wind_chime wind_face window wine_glass wing
+wink winking_face winking_face_with_tongue wireless wolf woman woman-biking woman-bouncing-ball woman-bowing
+woman-boy woman-boy-boy woman-cartwheeling woman-facepalming woman-frowning woman-gesturing-no
+woman-gesturing-ok woman-getting-haircut woman-getting-massage woman-girl woman-girl-boy woman-girl-girl
+woman-golfing woman-heart-man woman-heart-woman woman-juggling woman-kiss-man woman-kiss-woman
+woman-lifting-weights woman-mountain-biking woman-playing-handball woman-playing-water-polo woman-pouting
+woman-raising-hand woman-rowing-boat woman-running woman-shrugging woman-surfing woman-swimming
+woman-tipping-hand woman-walking woman-wearing-turban woman-woman-boy woman-woman-boy-boy woman-woman-girl
+woman-woman-girl-boy woman-woman-girl-girl woman-wrestling woman_and_man_holding_hands woman_artist
+woman_artist_tone1 woman_artist_tone2 woman_artist_tone3 woman_artist_tone4 woman_artist_tone5 woman_astronaut
+woman_astronaut_tone1 woman_astronaut_tone2 woman_astronaut_tone3 woman_astronaut_tone4 woman_astronaut_tone5
+woman_bald woman_beard woman_biking woman_biking_tone1 woman_biking_tone2 woman_biking_tone3
+woman_biking_tone4 woman_biking_tone5 woman_blond_hair woman_bouncing_ball woman_bouncing_ball_tone1
+woman_bouncing_ball_tone2 woman_bouncing_ball_tone3 woman_bouncing_ball_tone4 woman_bouncing_ball_tone5
+woman_bowing woman_bowing_tone1 woman_bowing_tone2 woman_bowing_tone3 woman_bowing_tone4 woman_bowing_tone5
+woman_cartwheeling woman_cartwheeling_tone1 woman_cartwheeling_tone2 woman_cartwheeling_tone3
+woman_cartwheeling_tone4 woman_cartwheeling_tone5 woman_climbing woman_climbing_tone1 woman_climbing_tone2
+woman_climbing_tone3 woman_climbing_tone4 woman_climbing_tone5 woman_construction_worker
+woman_construction_worker_tone1 woman_construction_worker_tone2 woman_construction_worker_tone3
+woman_construction_worker_tone4 woman_construction_worker_tone5 woman_cook woman_cook_tone1 woman_cook_tone2
+woman_cook_tone3 woman_cook_tone4 woman_cook_tone5 woman_curly_hair woman_dancing woman_detective
+woman_detective_tone1 woman_detective_tone2 woman_detective_tone3 woman_detective_tone4 woman_detective_tone5
+woman_elf woman_elf_tone1 woman_elf_tone2 woman_elf_tone3 woman_elf_tone4 woman_elf_tone5 woman_facepalming
+woman_facepalming_tone1 woman_facepalming_tone2 woman_facepalming_tone3 woman_facepalming_tone4
+woman_facepalming_tone5 woman_factory_worker woman_factory_worker_tone1 woman_factory_worker_tone2
+woman_factory_worker_tone3 woman_factory_worker_tone4 woman_factory_worker_tone5 woman_fairy woman_fairy_tone1
+woman_fairy_tone2 woman_fairy_tone3 woman_fairy_tone4 woman_fairy_tone5 woman_farmer woman_farmer_tone1
+woman_farmer_tone2 woman_farmer_tone3 woman_farmer_tone4 woman_farmer_tone5 woman_feeding_baby
+woman_firefighter woman_firefighter_tone1 woman_firefighter_tone2 woman_firefighter_tone3
+woman_firefighter_tone4 woman_firefighter_tone5 woman_frowning woman_frowning_tone1 woman_frowning_tone2
+woman_frowning_tone3 woman_frowning_tone4 woman_frowning_tone5 woman_genie woman_gesturing_no
+woman_gesturing_no_tone1 woman_gesturing_no_tone2 woman_gesturing_no_tone3 woman_gesturing_no_tone4
+woman_gesturing_no_tone5 woman_gesturing_ok woman_gesturing_ok_tone1 woman_gesturing_ok_tone2
+woman_gesturing_ok_tone3 woman_gesturing_ok_tone4 woman_gesturing_ok_tone5 woman_getting_face_massage
+woman_getting_face_massage_tone1 woman_getting_face_massage_tone2 woman_getting_face_massage_tone3
+woman_getting_face_massage_tone4 woman_getting_face_massage_tone5 woman_getting_haircut
+woman_getting_haircut_tone1 woman_getting_haircut_tone2 woman_getting_haircut_tone3
+woman_getting_haircut_tone4 woman_getting_haircut_tone5 woman_getting_massage woman_golfing
+woman_golfing_tone1 woman_golfing_tone2 woman_golfing_tone3 woman_golfing_tone4 woman_golfing_tone5
+woman_guard woman_guard_tone1 woman_guard_tone2 woman_guard_tone3 woman_guard_tone4 woman_guard_tone5
+woman_health_worker woman_health_worker_tone1 woman_health_worker_tone2 woman_health_worker_tone3
+woman_health_worker_tone4 woman_health_worker_tone5 woman_in_lotus_position woman_in_lotus_position_tone1
+woman_in_lotus_position_tone2 woman_in_lotus_position_tone3 woman_in_lotus_position_tone4
+woman_in_lotus_position_tone5 woman_in_manual_wheelchair woman_in_manual_wheelchair_facing_right
+woman_in_motorized_wheelchair woman_in_motorized_wheelchair_facing_right woman_in_steamy_room
+woman_in_steamy_room_tone1 woman_in_steamy_room_tone2 woman_in_steamy_room_tone3 woman_in_steamy_room_tone4
+woman_in_steamy_room_tone5 woman_in_tuxedo woman_judge woman_judge_tone1 woman_judge_tone2 woman_judge_tone3
+woman_judge_tone4 woman_judge_tone5 woman_juggling woman_juggling_tone1 woman_juggling_tone2
+woman_juggling_tone3 woman_juggling_tone4 woman_juggling_tone5 woman_kneeling woman_kneeling_facing_right
+woman_lifting_weights woman_lifting_weights_tone1 woman_lifting_weights_tone2 woman_lifting_weights_tone3
+woman_lifting_weights_tone4 woman_lifting_weights_tone5 woman_mage woman_mage_tone1 woman_mage_tone2
+woman_mage_tone3 woman_mage_tone4 woman_mage_tone5 woman_mechanic woman_mechanic_tone1 woman_mechanic_tone2
+woman_mechanic_tone3 woman_mechanic_tone4 woman_mechanic_tone5 woman_mountain_biking
+woman_mountain_biking_tone1 woman_mountain_biking_tone2 woman_mountain_biking_tone3
+woman_mountain_biking_tone4 woman_mountain_biking_tone5 woman_office_worker woman_office_worker_tone1
+woman_office_worker_tone2 woman_office_worker_tone3 woman_office_worker_tone4 woman_office_worker_tone5
+woman_pilot woman_pilot_tone1 woman_pilot_tone2 woman_pilot_tone3 woman_pilot_tone4 woman_pilot_tone5
+woman_playing_handball woman_playing_handball_tone1 woman_playing_handball_tone2 woman_playing_handball_tone3
+woman_playing_handball_tone4 woman_playing_handball_tone5 woman_playing_water_polo
+woman_playing_water_polo_tone1 woman_playing_water_polo_tone2 woman_playing_water_polo_tone3
+woman_playing_water_polo_tone4 woman_playing_water_polo_tone5 woman_police_officer woman_police_officer_tone1
+woman_police_officer_tone2 woman_police_officer_tone3 woman_police_officer_tone4 woman_police_officer_tone5
+woman_pouting woman_pouting_tone1 woman_pouting_tone2 woman_pouting_tone3 woman_pouting_tone4
+woman_pouting_tone5 woman_raising_hand woman_raising_hand_tone1 woman_raising_hand_tone2
+woman_raising_hand_tone3 woman_raising_hand_tone4 woman_raising_hand_tone5 woman_red_hair woman_rowing_boat
+woman_rowing_boat_tone1 woman_rowing_boat_tone2 woman_rowing_boat_tone3 woman_rowing_boat_tone4
+woman_rowing_boat_tone5 woman_running woman_running_facing_right woman_running_tone1 woman_running_tone2
+woman_running_tone3 woman_running_tone4 woman_running_tone5 woman_scientist woman_scientist_tone1
+woman_scientist_tone2 woman_scientist_tone3 woman_scientist_tone4 woman_scientist_tone5 woman_shrugging
+woman_shrugging_tone1 woman_shrugging_tone2 woman_shrugging_tone3 woman_shrugging_tone4 woman_shrugging_tone5
+woman_singer woman_singer_tone1 woman_singer_tone2 woman_singer_tone3 woman_singer_tone4 woman_singer_tone5
+woman_standing woman_student woman_student_tone1 woman_student_tone2 woman_student_tone3 woman_student_tone4
+woman_student_tone5 woman_superhero woman_supervillain woman_surfing woman_surfing_tone1 woman_surfing_tone2
+woman_surfing_tone3 woman_surfing_tone4 woman_surfing_tone5 woman_swimming woman_swimming_tone1
+woman_swimming_tone2 woman_swimming_tone3 woman_swimming_tone4 woman_swimming_tone5 woman_teacher
+woman_teacher_tone1 woman_teacher_tone2 woman_teacher_tone3 woman_teacher_tone4 woman_teacher_tone5
+woman_technologist woman_technologist_tone1 woman_technologist_tone2 woman_technologist_tone3
+woman_technologist_tone4 woman_technologist_tone5 woman_tipping_hand woman_tipping_hand_tone1
+woman_tipping_hand_tone2 woman_tipping_hand_tone3 woman_tipping_hand_tone4 woman_tipping_hand_tone5
+woman_tone1 woman_tone2 woman_tone3 woman_tone4 woman_tone5 woman_vampire woman_vampire_tone1
+woman_vampire_tone2 woman_vampire_tone3 woman_vampire_tone4 woman_vampire_tone5 woman_walking
+woman_walking_facing_right woman_walking_tone1 woman_walking_tone2 woman_walking_tone3 woman_walking_tone4
+woman_walking_tone5 woman_wearing_turban woman_wearing_turban_tone1 woman_wearing_turban_tone2
+woman_wearing_turban_tone3 woman_wearing_turban_tone4 woman_wearing_turban_tone5 woman_white_hair
+woman_with_beard woman_with_headscarf woman_with_headscarf_tone1 woman_with_headscarf_tone2
+woman_with_headscarf_tone3 woman_with_headscarf_tone4 woman_with_headscarf_tone5 woman_with_probing_cane
+woman_with_turban woman_with_veil woman_with_white_cane woman_with_white_cane_facing_right woman_zombie
+womans_clothes womans_flat_shoe womans_hat women-with-bunny-ears-partying women_holding_hands
+women_with_bunny_ears women_with_bunny_ears_partying women_wrestling womens wood woozy_face world_map worm
+worried worried_face wrapped_gift wrench wrestlers wrestling writing_hand writing_hand_tone1
+writing_hand_tone2 writing_hand_tone3 writing_hand_tone4 writing_hand_tone5 x x-ray x_ray yarn yawning_face
+yellow_circle yellow_heart yellow_square yemen yen yen_banknote yin_yang yo-yo yo_yo yum zambia zany_face zap
+zebra zebra_face zero zimbabwe zipper-mouth_face zipper_mouth zipper_mouth_face zombie zombie_man zombie_woman
+zzz
+`
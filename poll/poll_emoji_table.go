@@ -0,0 +1,816 @@
+package poll
+
+// unicodeEmojis maps Unicode emojis to the short names Mattermost uses for them. Variation selectors are left out of the keys.
+// Emojis with tones can be combined with the skin tone modifiers, see emojiNames.
+var unicodeEmojis = map[string]unicodeEmoji{
+	"😀": {"grinning", false},
+	"😃": {"smiley", false},
+	"😄": {"smile", false},
+	"😁": {"grin", false},
+	"😆": {"laughing", false},
+	"😅": {"sweat_smile", false},
+	"🤣": {"rolling_on_the_floor_laughing", false},
+	"😂": {"joy", false},
+	"🙂": {"slightly_smiling_face", false},
+	"🙃": {"upside_down_face", false},
+	"😉": {"wink", false},
+	"😊": {"blush", false},
+	"😇": {"innocent", false},
+	"😍": {"heart_eyes", false},
+	"😘": {"kissing_heart", false},
+	"😋": {"yum", false},
+	"😜": {"stuck_out_tongue_winking_eye", false},
+	"🤔": {"thinking_face", false},
+	"🤐": {"zipper_mouth_face", false},
+	"😐": {"neutral_face", false},
+	"😑": {"expressionless", false},
+	"😶": {"no_mouth", false},
+	"😏": {"smirk", false},
+	"😒": {"unamused", false},
+	"🙄": {"face_with_rolling_eyes", false},
+	"😬": {"grimacing", false},
+	"😌": {"relieved", false},
+	"😔": {"pensive", false},
+	"😪": {"sleepy", false},
+	"😴": {"sleeping", false},
+	"😷": {"mask", false},
+	"🤒": {"face_with_thermometer", false},
+	"🤢": {"nauseated_face", false},
+	"🤧": {"sneezing_face", false},
+	"😎": {"sunglasses", false},
+	"🤓": {"nerd_face", false},
+	"😕": {"confused", false},
+	"😟": {"worried", false},
+	"😮": {"open_mouth", false},
+	"😲": {"astonished", false},
+	"😳": {"flushed", false},
+	"😦": {"frowning", false},
+	"😨": {"fearful", false},
+	"😰": {"cold_sweat", false},
+	"😢": {"cry", false},
+	"😭": {"sob", false},
+	"😱": {"scream", false},
+	"😖": {"confounded", false},
+	"😣": {"persevere", false},
+	"😞": {"disappointed", false},
+	"😓": {"sweat", false},
+	"😩": {"weary", false},
+	"😫": {"tired_face", false},
+	"😤": {"triumph", false},
+	"😡": {"rage", false},
+	"😠": {"angry", false},
+	"😈": {"smiling_imp", false},
+	"💀": {"skull", false},
+	"💩": {"hankey", false},
+	"🤡": {"clown_face", false},
+	"👻": {"ghost", false},
+	"👽": {"alien", false},
+	"🤖": {"robot_face", false},
+	"🙈": {"see_no_evil", false},
+	"🙉": {"hear_no_evil", false},
+	"🙊": {"speak_no_evil", false},
+	"👋": {"wave", true},
+	"✋": {"hand", true},
+	"👌": {"ok_hand", true},
+	"✌": {"v", true},
+	"🤞": {"crossed_fingers", true},
+	"🤘": {"the_horns", true},
+	"🤙": {"call_me_hand", true},
+	"👈": {"point_left", true},
+	"👉": {"point_right", true},
+	"👆": {"point_up_2", true},
+	"👇": {"point_down", true},
+	"☝": {"point_up", true},
+	"👍": {"+1", true},
+	"👎": {"-1", true},
+	"✊": {"fist", true},
+	"👊": {"facepunch", true},
+	"👏": {"clap", true},
+	"🙌": {"raised_hands", true},
+	"👐": {"open_hands", true},
+	"🙏": {"pray", true},
+	"🤝": {"handshake", false},
+	"💪": {"muscle", true},
+	"👀": {"eyes", false},
+	"🤷": {"shrug", true},
+	"🤦": {"face_palm", true},
+	"🙋": {"raising_hand", true},
+	"🙅": {"no_good", true},
+	"🙆": {"ok_woman", true},
+	"👨": {"man", true},
+	"👩": {"woman", true},
+	"👶": {"baby", true},
+	"❤": {"heart", false},
+	"🧡": {"orange_heart", false},
+	"💛": {"yellow_heart", false},
+	"💚": {"green_heart", false},
+	"💙": {"blue_heart", false},
+	"💜": {"purple_heart", false},
+	"🖤": {"black_heart", false},
+	"💔": {"broken_heart", false},
+	"💯": {"100", false},
+	"💥": {"boom", false},
+	"💤": {"zzz", false},
+	"💬": {"speech_balloon", false},
+	"🐶": {"dog", false},
+	"🐱": {"cat", false},
+	"🐭": {"mouse", false},
+	"🐰": {"rabbit", false},
+	"🦊": {"fox_face", false},
+	"🐻": {"bear", false},
+	"🐼": {"panda_face", false},
+	"🐨": {"koala", false},
+	"🐯": {"tiger", false},
+	"🦁": {"lion_face", false},
+	"🐮": {"cow", false},
+	"🐷": {"pig", false},
+	"🐸": {"frog", false},
+	"🐵": {"monkey_face", false},
+	"🐔": {"chicken", false},
+	"🐧": {"penguin", false},
+	"🐦": {"bird", false},
+	"🦆": {"duck", false},
+	"🦉": {"owl", false},
+	"🦄": {"unicorn_face", false},
+	"🐝": {"bee", false},
+	"🐛": {"bug", false},
+	"🦋": {"butterfly", false},
+	"🐌": {"snail", false},
+	"🐢": {"turtle", false},
+	"🐍": {"snake", false},
+	"🐙": {"octopus", false},
+	"🐠": {"tropical_fish", false},
+	"🐬": {"dolphin", false},
+	"🐳": {"whale", false},
+	"🦈": {"shark", false},
+	"🌲": {"evergreen_tree", false},
+	"🌳": {"deciduous_tree", false},
+	"🌵": {"cactus", false},
+	"🌻": {"sunflower", false},
+	"🌹": {"rose", false},
+	"🌷": {"tulip", false},
+	"🍀": {"four_leaf_clover", false},
+	"🍁": {"maple_leaf", false},
+	"🍏": {"green_apple", false},
+	"🍎": {"apple", false},
+	"🍐": {"pear", false},
+	"🍊": {"tangerine", false},
+	"🍋": {"lemon", false},
+	"🍌": {"banana", false},
+	"🍉": {"watermelon", false},
+	"🍇": {"grapes", false},
+	"🍓": {"strawberry", false},
+	"🍒": {"cherries", false},
+	"🍑": {"peach", false},
+	"🍍": {"pineapple", false},
+	"🥝": {"kiwifruit", false},
+	"🥑": {"avocado", false},
+	"🍅": {"tomato", false},
+	"🍆": {"eggplant", false},
+	"🥕": {"carrot", false},
+	"🌽": {"corn", false},
+	"🌶": {"hot_pepper", false},
+	"🥔": {"potato", false},
+	"🥐": {"croissant", false},
+	"🍞": {"bread", false},
+	"🥖": {"baguette_bread", false},
+	"🧀": {"cheese_wedge", false},
+	"🥚": {"egg", false},
+	"🍳": {"fried_egg", false},
+	"🥓": {"bacon", false},
+	"🥞": {"pancakes", false},
+	"🍗": {"poultry_leg", false},
+	"🍖": {"meat_on_bone", false},
+	"🌭": {"hotdog", false},
+	"🍔": {"hamburger", false},
+	"🍟": {"fries", false},
+	"🍕": {"pizza", false},
+	"🌮": {"taco", false},
+	"🌯": {"burrito", false},
+	"🥗": {"green_salad", false},
+	"🍝": {"spaghetti", false},
+	"🍜": {"ramen", false},
+	"🍲": {"stew", false},
+	"🍛": {"curry", false},
+	"🍣": {"sushi", false},
+	"🍱": {"bento", false},
+	"🍤": {"fried_shrimp", false},
+	"🍙": {"rice_ball", false},
+	"🍚": {"rice", false},
+	"🍦": {"icecream", false},
+	"🍩": {"doughnut", false},
+	"🍪": {"cookie", false},
+	"🎂": {"birthday", false},
+	"🍰": {"cake", false},
+	"🍫": {"chocolate_bar", false},
+	"🍬": {"candy", false},
+	"🍭": {"lollipop", false},
+	"🍿": {"popcorn", false},
+	"☕": {"coffee", false},
+	"🍵": {"tea", false},
+	"🍺": {"beer", false},
+	"🍻": {"beers", false},
+	"🍷": {"wine_glass", false},
+	"🍸": {"cocktail", false},
+	"🍹": {"tropical_drink", false},
+	"🥂": {"clinking_glasses", false},
+	"⚽": {"soccer", false},
+	"🏀": {"basketball", false},
+	"🏈": {"football", false},
+	"⚾": {"baseball", false},
+	"🎾": {"tennis", false},
+	"🏐": {"volleyball", false},
+	"🎱": {"8ball", false},
+	"🏓": {"table_tennis_paddle_and_ball", false},
+	"🎮": {"video_game", false},
+	"🎲": {"game_die", false},
+	"🎯": {"dart", false},
+	"🎳": {"bowling", false},
+	"🎸": {"guitar", false},
+	"🎵": {"musical_note", false},
+	"🎤": {"microphone", false},
+	"🎬": {"clapper", false},
+	"🎨": {"art", false},
+	"🏆": {"trophy", false},
+	"🥇": {"first_place_medal", false},
+	"🥈": {"second_place_medal", false},
+	"🥉": {"third_place_medal", false},
+	"🚗": {"car", false},
+	"🚕": {"taxi", false},
+	"🚌": {"bus", false},
+	"🚲": {"bike", false},
+	"✈": {"airplane", false},
+	"🚀": {"rocket", false},
+	"🚢": {"ship", false},
+	"🚂": {"steam_locomotive", false},
+	"🏠": {"house", false},
+	"🏢": {"office", false},
+	"🏖": {"beach_with_umbrella", false},
+	"⛰": {"mountain", false},
+	"☀": {"sunny", false},
+	"⛅": {"partly_sunny", false},
+	"☁": {"cloud", false},
+	"🌧": {"rain_cloud", false},
+	"⛈": {"thunder_cloud_and_rain", false},
+	"⚡": {"zap", false},
+	"❄": {"snowflake", false},
+	"☃": {"snowman", false},
+	"🔥": {"fire", false},
+	"💧": {"droplet", false},
+	"🌊": {"ocean", false},
+	"🌈": {"rainbow", false},
+	"⭐": {"star", false},
+	"🌟": {"star2", false},
+	"🌙": {"crescent_moon", false},
+	"🌍": {"earth_africa", false},
+	"☔": {"umbrella_with_rain_drops", false},
+	"🎉": {"tada", false},
+	"🎈": {"balloon", false},
+	"🎁": {"gift", false},
+	"🎄": {"christmas_tree", false},
+	"🎃": {"jack_o_lantern", false},
+	"📱": {"iphone", false},
+	"💻": {"computer", false},
+	"⌨": {"keyboard", false},
+	"🖥": {"desktop_computer", false},
+	"📷": {"camera", false},
+	"📺": {"tv", false},
+	"📞": {"telephone_receiver", false},
+	"⏰": {"alarm_clock", false},
+	"⌛": {"hourglass", false},
+	"💡": {"bulb", false},
+	"🔋": {"battery", false},
+	"🔌": {"electric_plug", false},
+	"💰": {"moneybag", false},
+	"💵": {"dollar", false},
+	"💳": {"credit_card", false},
+	"🔧": {"wrench", false},
+	"🔨": {"hammer", false},
+	"🔩": {"nut_and_bolt", false},
+	"⚙": {"gear", false},
+	"🔒": {"lock", false},
+	"🔓": {"unlock", false},
+	"🔑": {"key", false},
+	"📧": {"e-mail", false},
+	"📦": {"package", false},
+	"📝": {"memo", false},
+	"📅": {"date", false},
+	"📈": {"chart_with_upwards_trend", false},
+	"📉": {"chart_with_downwards_trend", false},
+	"📊": {"bar_chart", false},
+	"📌": {"pushpin", false},
+	"📎": {"paperclip", false},
+	"✏": {"pencil2", false},
+	"📚": {"books", false},
+	"📖": {"book", false},
+	"🔍": {"mag", false},
+	"🔔": {"bell", false},
+	"🔕": {"no_bell", false},
+	"📢": {"loudspeaker", false},
+	"📣": {"mega", false},
+	"🚪": {"door", false},
+	"🚽": {"toilet", false},
+	"🚿": {"shower", false},
+	"✅": {"white_check_mark", false},
+	"✔": {"heavy_check_mark", false},
+	"☑": {"ballot_box_with_check", false},
+	"❌": {"x", false},
+	"❎": {"negative_squared_cross_mark", false},
+	"➕": {"heavy_plus_sign", false},
+	"➖": {"heavy_minus_sign", false},
+	"❓": {"question", false},
+	"❗": {"exclamation", false},
+	"⚠": {"warning", false},
+	"🚫": {"no_entry_sign", false},
+	"⛔": {"no_entry", false},
+	"🔴": {"red_circle", false},
+	"🔵": {"large_blue_circle", false},
+	"⚪": {"white_circle", false},
+	"⚫": {"black_circle", false},
+	"🟢": {"large_green_circle", false},
+	"🟡": {"large_yellow_circle", false},
+	"🟠": {"large_orange_circle", false},
+	"🟣": {"large_purple_circle", false},
+	"🟤": {"large_brown_circle", false},
+	"⬆": {"arrow_up", false},
+	"⬇": {"arrow_down", false},
+	"⬅": {"arrow_left", false},
+	"➡": {"arrow_right", false},
+	"🔄": {"arrows_counterclockwise", false},
+	"🆕": {"new", false},
+	"🆗": {"ok", false},
+	"🆙": {"up", false},
+	"🆒": {"cool", false},
+	"🆓": {"free", false},
+	"🆖": {"ng", false},
+	"🆘": {"sos", false},
+	"🔟": {"keycap_ten", false},
+	"🕐": {"clock1", false},
+	"🕑": {"clock2", false},
+	"🕒": {"clock3", false},
+	"🕓": {"clock4", false},
+	"🕔": {"clock5", false},
+	"🕕": {"clock6", false},
+	"🕖": {"clock7", false},
+	"🕗": {"clock8", false},
+	"🕘": {"clock9", false},
+	"🕙": {"clock10", false},
+	"🕚": {"clock11", false},
+	"🕛": {"clock12", false},
+	"🕜": {"clock130", false},
+	"🕝": {"clock230", false},
+	"🕞": {"clock330", false},
+	"🕟": {"clock430", false},
+	"🕠": {"clock530", false},
+	"🕡": {"clock630", false},
+	"🕢": {"clock730", false},
+	"🕣": {"clock830", false},
+	"🕤": {"clock930", false},
+	"🕥": {"clock1030", false},
+	"🕦": {"clock1130", false},
+	"🕧": {"clock1230", false},
+	"🥳": {"partying_face", false},
+	"🥰": {"smiling_face_with_3_hearts", false},
+	"🥺": {"pleading_face", false},
+	"🤩": {"star-struck", false},
+	"🤯": {"exploding_head", false},
+	"🥱": {"yawning_face", false},
+	"🥴": {"woozy_face", false},
+	"🤪": {"zany_face", false},
+	"🥶": {"cold_face", false},
+	"🥵": {"hot_face", false},
+	"🤗": {"hugging_face", false},
+	"🤭": {"face_with_hand_over_mouth", false},
+	"🤫": {"shushing_face", false},
+	"🧐": {"face_with_monocle", false},
+	"🤠": {"face_with_cowboy_hat", false},
+	"🤥": {"lying_face", false},
+	"🤤": {"drooling_face", false},
+	"🤮": {"face_vomiting", false},
+	"🤕": {"face_with_head_bandage", false},
+	"🤑": {"money_mouth_face", false},
+	"🤨": {"face_with_raised_eyebrow", false},
+	"🤟": {"i_love_you_hand_sign", true},
+	"🤲": {"palms_up_together", true},
+	"🦾": {"mechanical_arm", false},
+	"👪": {"family", false},
+
+	// Keycaps
+	"#\u20E3": {"hash", false},
+	"*\u20E3": {"keycap_star", false},
+	"0\u20E3": {"zero", false},
+	"1\u20E3": {"one", false},
+	"2\u20E3": {"two", false},
+	"3\u20E3": {"three", false},
+	"4\u20E3": {"four", false},
+	"5\u20E3": {"five", false},
+	"6\u20E3": {"six", false},
+	"7\u20E3": {"seven", false},
+	"8\u20E3": {"eight", false},
+	"9\u20E3": {"nine", false},
+
+	// Regional indicators, pairs of them are flags
+	"🇦": {"regional_indicator_a", false},
+	"🇧": {"regional_indicator_b", false},
+	"🇨": {"regional_indicator_c", false},
+	"🇩": {"regional_indicator_d", false},
+	"🇪": {"regional_indicator_e", false},
+	"🇫": {"regional_indicator_f", false},
+	"🇬": {"regional_indicator_g", false},
+	"🇭": {"regional_indicator_h", false},
+	"🇮": {"regional_indicator_i", false},
+	"🇯": {"regional_indicator_j", false},
+	"🇰": {"regional_indicator_k", false},
+	"🇱": {"regional_indicator_l", false},
+	"🇲": {"regional_indicator_m", false},
+	"🇳": {"regional_indicator_n", false},
+	"🇴": {"regional_indicator_o", false},
+	"🇵": {"regional_indicator_p", false},
+	"🇶": {"regional_indicator_q", false},
+	"🇷": {"regional_indicator_r", false},
+	"🇸": {"regional_indicator_s", false},
+	"🇹": {"regional_indicator_t", false},
+	"🇺": {"regional_indicator_u", false},
+	"🇻": {"regional_indicator_v", false},
+	"🇼": {"regional_indicator_w", false},
+	"🇽": {"regional_indicator_x", false},
+	"🇾": {"regional_indicator_y", false},
+	"🇿": {"regional_indicator_z", false},
+
+	"🇦🇨": {"flag-ac", false},
+	"🇦🇩": {"flag-ad", false},
+	"🇦🇪": {"flag-ae", false},
+	"🇦🇫": {"flag-af", false},
+	"🇦🇬": {"flag-ag", false},
+	"🇦🇮": {"flag-ai", false},
+	"🇦🇱": {"flag-al", false},
+	"🇦🇲": {"flag-am", false},
+	"🇦🇴": {"flag-ao", false},
+	"🇦🇶": {"flag-aq", false},
+	"🇦🇷": {"flag-ar", false},
+	"🇦🇸": {"flag-as", false},
+	"🇦🇹": {"flag-at", false},
+	"🇦🇺": {"flag-au", false},
+	"🇦🇼": {"flag-aw", false},
+	"🇦🇽": {"flag-ax", false},
+	"🇦🇿": {"flag-az", false},
+	"🇧🇦": {"flag-ba", false},
+	"🇧🇧": {"flag-bb", false},
+	"🇧🇩": {"flag-bd", false},
+	"🇧🇪": {"flag-be", false},
+	"🇧🇫": {"flag-bf", false},
+	"🇧🇬": {"flag-bg", false},
+	"🇧🇭": {"flag-bh", false},
+	"🇧🇮": {"flag-bi", false},
+	"🇧🇯": {"flag-bj", false},
+	"🇧🇱": {"flag-bl", false},
+	"🇧🇲": {"flag-bm", false},
+	"🇧🇳": {"flag-bn", false},
+	"🇧🇴": {"flag-bo", false},
+	"🇧🇶": {"flag-bq", false},
+	"🇧🇷": {"flag-br", false},
+	"🇧🇸": {"flag-bs", false},
+	"🇧🇹": {"flag-bt", false},
+	"🇧🇻": {"flag-bv", false},
+	"🇧🇼": {"flag-bw", false},
+	"🇧🇾": {"flag-by", false},
+	"🇧🇿": {"flag-bz", false},
+	"🇨🇦": {"flag-ca", false},
+	"🇨🇨": {"flag-cc", false},
+	"🇨🇩": {"flag-cd", false},
+	"🇨🇫": {"flag-cf", false},
+	"🇨🇬": {"flag-cg", false},
+	"🇨🇭": {"flag-ch", false},
+	"🇨🇮": {"flag-ci", false},
+	"🇨🇰": {"flag-ck", false},
+	"🇨🇱": {"flag-cl", false},
+	"🇨🇲": {"flag-cm", false},
+	"🇨🇳": {"cn", false},
+	"🇨🇴": {"flag-co", false},
+	"🇨🇵": {"flag-cp", false},
+	"🇨🇷": {"flag-cr", false},
+	"🇨🇺": {"flag-cu", false},
+	"🇨🇻": {"flag-cv", false},
+	"🇨🇼": {"flag-cw", false},
+	"🇨🇽": {"flag-cx", false},
+	"🇨🇾": {"flag-cy", false},
+	"🇨🇿": {"flag-cz", false},
+	"🇩🇪": {"de", false},
+	"🇩🇬": {"flag-dg", false},
+	"🇩🇯": {"flag-dj", false},
+	"🇩🇰": {"flag-dk", false},
+	"🇩🇲": {"flag-dm", false},
+	"🇩🇴": {"flag-do", false},
+	"🇩🇿": {"flag-dz", false},
+	"🇪🇦": {"flag-ea", false},
+	"🇪🇨": {"flag-ec", false},
+	"🇪🇪": {"flag-ee", false},
+	"🇪🇬": {"flag-eg", false},
+	"🇪🇭": {"flag-eh", false},
+	"🇪🇷": {"flag-er", false},
+	"🇪🇸": {"es", false},
+	"🇪🇹": {"flag-et", false},
+	"🇪🇺": {"flag-eu", false},
+	"🇫🇮": {"flag-fi", false},
+	"🇫🇯": {"flag-fj", false},
+	"🇫🇰": {"flag-fk", false},
+	"🇫🇲": {"flag-fm", false},
+	"🇫🇴": {"flag-fo", false},
+	"🇫🇷": {"fr", false},
+	"🇬🇦": {"flag-ga", false},
+	"🇬🇧": {"gb", false},
+	"🇬🇩": {"flag-gd", false},
+	"🇬🇪": {"flag-ge", false},
+	"🇬🇫": {"flag-gf", false},
+	"🇬🇬": {"flag-gg", false},
+	"🇬🇭": {"flag-gh", false},
+	"🇬🇮": {"flag-gi", false},
+	"🇬🇱": {"flag-gl", false},
+	"🇬🇲": {"flag-gm", false},
+	"🇬🇳": {"flag-gn", false},
+	"🇬🇵": {"flag-gp", false},
+	"🇬🇶": {"flag-gq", false},
+	"🇬🇷": {"flag-gr", false},
+	"🇬🇸": {"flag-gs", false},
+	"🇬🇹": {"flag-gt", false},
+	"🇬🇺": {"flag-gu", false},
+	"🇬🇼": {"flag-gw", false},
+	"🇬🇾": {"flag-gy", false},
+	"🇭🇰": {"flag-hk", false},
+	"🇭🇲": {"flag-hm", false},
+	"🇭🇳": {"flag-hn", false},
+	"🇭🇷": {"flag-hr", false},
+	"🇭🇹": {"flag-ht", false},
+	"🇭🇺": {"flag-hu", false},
+	"🇮🇨": {"flag-ic", false},
+	"🇮🇩": {"flag-id", false},
+	"🇮🇪": {"flag-ie", false},
+	"🇮🇱": {"flag-il", false},
+	"🇮🇲": {"flag-im", false},
+	"🇮🇳": {"flag-in", false},
+	"🇮🇴": {"flag-io", false},
+	"🇮🇶": {"flag-iq", false},
+	"🇮🇷": {"flag-ir", false},
+	"🇮🇸": {"flag-is", false},
+	"🇮🇹": {"it", false},
+	"🇯🇪": {"flag-je", false},
+	"🇯🇲": {"flag-jm", false},
+	"🇯🇴": {"flag-jo", false},
+	"🇯🇵": {"jp", false},
+	"🇰🇪": {"flag-ke", false},
+	"🇰🇬": {"flag-kg", false},
+	"🇰🇭": {"flag-kh", false},
+	"🇰🇮": {"flag-ki", false},
+	"🇰🇲": {"flag-km", false},
+	"🇰🇳": {"flag-kn", false},
+	"🇰🇵": {"flag-kp", false},
+	"🇰🇷": {"kr", false},
+	"🇰🇼": {"flag-kw", false},
+	"🇰🇾": {"flag-ky", false},
+	"🇰🇿": {"flag-kz", false},
+	"🇱🇦": {"flag-la", false},
+	"🇱🇧": {"flag-lb", false},
+	"🇱🇨": {"flag-lc", false},
+	"🇱🇮": {"flag-li", false},
+	"🇱🇰": {"flag-lk", false},
+	"🇱🇷": {"flag-lr", false},
+	"🇱🇸": {"flag-ls", false},
+	"🇱🇹": {"flag-lt", false},
+	"🇱🇺": {"flag-lu", false},
+	"🇱🇻": {"flag-lv", false},
+	"🇱🇾": {"flag-ly", false},
+	"🇲🇦": {"flag-ma", false},
+	"🇲🇨": {"flag-mc", false},
+	"🇲🇩": {"flag-md", false},
+	"🇲🇪": {"flag-me", false},
+	"🇲🇫": {"flag-mf", false},
+	"🇲🇬": {"flag-mg", false},
+	"🇲🇭": {"flag-mh", false},
+	"🇲🇰": {"flag-mk", false},
+	"🇲🇱": {"flag-ml", false},
+	"🇲🇲": {"flag-mm", false},
+	"🇲🇳": {"flag-mn", false},
+	"🇲🇴": {"flag-mo", false},
+	"🇲🇵": {"flag-mp", false},
+	"🇲🇶": {"flag-mq", false},
+	"🇲🇷": {"flag-mr", false},
+	"🇲🇸": {"flag-ms", false},
+	"🇲🇹": {"flag-mt", false},
+	"🇲🇺": {"flag-mu", false},
+	"🇲🇻": {"flag-mv", false},
+	"🇲🇼": {"flag-mw", false},
+	"🇲🇽": {"flag-mx", false},
+	"🇲🇾": {"flag-my", false},
+	"🇲🇿": {"flag-mz", false},
+	"🇳🇦": {"flag-na", false},
+	"🇳🇨": {"flag-nc", false},
+	"🇳🇪": {"flag-ne", false},
+	"🇳🇫": {"flag-nf", false},
+	"🇳🇬": {"flag-ng", false},
+	"🇳🇮": {"flag-ni", false},
+	"🇳🇱": {"flag-nl", false},
+	"🇳🇴": {"flag-no", false},
+	"🇳🇵": {"flag-np", false},
+	"🇳🇷": {"flag-nr", false},
+	"🇳🇺": {"flag-nu", false},
+	"🇳🇿": {"flag-nz", false},
+	"🇴🇲": {"flag-om", false},
+	"🇵🇦": {"flag-pa", false},
+	"🇵🇪": {"flag-pe", false},
+	"🇵🇫": {"flag-pf", false},
+	"🇵🇬": {"flag-pg", false},
+	"🇵🇭": {"flag-ph", false},
+	"🇵🇰": {"flag-pk", false},
+	"🇵🇱": {"flag-pl", false},
+	"🇵🇲": {"flag-pm", false},
+	"🇵🇳": {"flag-pn", false},
+	"🇵🇷": {"flag-pr", false},
+	"🇵🇸": {"flag-ps", false},
+	"🇵🇹": {"flag-pt", false},
+	"🇵🇼": {"flag-pw", false},
+	"🇵🇾": {"flag-py", false},
+	"🇶🇦": {"flag-qa", false},
+	"🇷🇪": {"flag-re", false},
+	"🇷🇴": {"flag-ro", false},
+	"🇷🇸": {"flag-rs", false},
+	"🇷🇺": {"ru", false},
+	"🇷🇼": {"flag-rw", false},
+	"🇸🇦": {"flag-sa", false},
+	"🇸🇧": {"flag-sb", false},
+	"🇸🇨": {"flag-sc", false},
+	"🇸🇩": {"flag-sd", false},
+	"🇸🇪": {"flag-se", false},
+	"🇸🇬": {"flag-sg", false},
+	"🇸🇭": {"flag-sh", false},
+	"🇸🇮": {"flag-si", false},
+	"🇸🇯": {"flag-sj", false},
+	"🇸🇰": {"flag-sk", false},
+	"🇸🇱": {"flag-sl", false},
+	"🇸🇲": {"flag-sm", false},
+	"🇸🇳": {"flag-sn", false},
+	"🇸🇴": {"flag-so", false},
+	"🇸🇷": {"flag-sr", false},
+	"🇸🇸": {"flag-ss", false},
+	"🇸🇹": {"flag-st", false},
+	"🇸🇻": {"flag-sv", false},
+	"🇸🇽": {"flag-sx", false},
+	"🇸🇾": {"flag-sy", false},
+	"🇸🇿": {"flag-sz", false},
+	"🇹🇦": {"flag-ta", false},
+	"🇹🇨": {"flag-tc", false},
+	"🇹🇩": {"flag-td", false},
+	"🇹🇫": {"flag-tf", false},
+	"🇹🇬": {"flag-tg", false},
+	"🇹🇭": {"flag-th", false},
+	"🇹🇯": {"flag-tj", false},
+	"🇹🇰": {"flag-tk", false},
+	"🇹🇱": {"flag-tl", false},
+	"🇹🇲": {"flag-tm", false},
+	"🇹🇳": {"flag-tn", false},
+	"🇹🇴": {"flag-to", false},
+	"🇹🇷": {"flag-tr", false},
+	"🇹🇹": {"flag-tt", false},
+	"🇹🇻": {"flag-tv", false},
+	"🇹🇼": {"flag-tw", false},
+	"🇹🇿": {"flag-tz", false},
+	"🇺🇦": {"flag-ua", false},
+	"🇺🇬": {"flag-ug", false},
+	"🇺🇲": {"flag-um", false},
+	"🇺🇳": {"flag-un", false},
+	"🇺🇸": {"us", false},
+	"🇺🇾": {"flag-uy", false},
+	"🇺🇿": {"flag-uz", false},
+	"🇻🇦": {"flag-va", false},
+	"🇻🇨": {"flag-vc", false},
+	"🇻🇪": {"flag-ve", false},
+	"🇻🇬": {"flag-vg", false},
+	"🇻🇮": {"flag-vi", false},
+	"🇻🇳": {"flag-vn", false},
+	"🇻🇺": {"flag-vu", false},
+	"🇼🇫": {"flag-wf", false},
+	"🇼🇸": {"flag-ws", false},
+	"🇽🇰": {"flag-xk", false},
+	"🇾🇪": {"flag-ye", false},
+	"🇾🇹": {"flag-yt", false},
+	"🇿🇦": {"flag-za", false},
+	"🇿🇲": {"flag-zm", false},
+	"🇿🇼": {"flag-zw", false},
+
+	// Sequences joined by zero width joiners
+	"\U0001F468\u200D\U0001F4BB":                                 {"male-technologist", true},
+	"\U0001F469\u200D\U0001F4BB":                                 {"female-technologist", true},
+	"\U0001F468\u200D\U0001F373":                                 {"male-cook", true},
+	"\U0001F469\u200D\U0001F373":                                 {"female-cook", true},
+	"\U0001F468\u200D\U0001F393":                                 {"male-student", true},
+	"\U0001F469\u200D\U0001F393":                                 {"female-student", true},
+	"\U0001F468\u200D\U0001F3EB":                                 {"male-teacher", true},
+	"\U0001F469\u200D\U0001F3EB":                                 {"female-teacher", true},
+	"\U0001F468\u200D\U0001F52C":                                 {"male-scientist", true},
+	"\U0001F469\u200D\U0001F52C":                                 {"female-scientist", true},
+	"\U0001F468\u200D\U0001F680":                                 {"male-astronaut", true},
+	"\U0001F469\u200D\U0001F680":                                 {"female-astronaut", true},
+	"\U0001F937\u200D\u2642":                                     {"man-shrugging", true},
+	"\U0001F937\u200D\u2640":                                     {"woman-shrugging", true},
+	"\U0001F926\u200D\u2642":                                     {"man-facepalming", true},
+	"\U0001F926\u200D\u2640":                                     {"woman-facepalming", true},
+	"\U0001F468\u200D\U0001F469\u200D\U0001F467\u200D\U0001F466": {"man-woman-girl-boy", false},
+	"\U0001F468\u200D\U0001F469\u200D\U0001F466":                 {"man-woman-boy", false},
+	"\U0001F3F3\u200D\U0001F308":                                 {"rainbow-flag", false},
+	"\U0001F3F4\u200D\u2620":                                     {"pirate_flag", false},
+	"\U0001F441\u200D\U0001F5E8":                                 {"eye-in-speech-bubble", false},
+	"\U0001F415\u200D\U0001F9BA":                                 {"service_dog", false},
+	"\U0001F43B\u200D\u2744":                                     {"polar_bear", false},
+	"\U0001F468\u200D\U0001F469\u200D\U0001F467":                 {"man-woman-girl", false},
+	"\U0001F468\u200D\U0001F469\u200D\U0001F466\u200D\U0001F466": {"man-woman-boy-boy", false},
+	"\U0001F468\u200D\U0001F469\u200D\U0001F467\u200D\U0001F467": {"man-woman-girl-girl", false},
+	"\U0001F468\u200D\U0001F468\u200D\U0001F466":                 {"man-man-boy", false},
+	"\U0001F468\u200D\U0001F468\u200D\U0001F467":                 {"man-man-girl", false},
+	"\U0001F468\u200D\U0001F468\u200D\U0001F467\u200D\U0001F466": {"man-man-girl-boy", false},
+	"\U0001F468\u200D\U0001F468\u200D\U0001F466\u200D\U0001F466": {"man-man-boy-boy", false},
+	"\U0001F468\u200D\U0001F468\u200D\U0001F467\u200D\U0001F467": {"man-man-girl-girl", false},
+	"\U0001F469\u200D\U0001F469\u200D\U0001F466":                 {"woman-woman-boy", false},
+	"\U0001F469\u200D\U0001F469\u200D\U0001F467":                 {"woman-woman-girl", false},
+	"\U0001F469\u200D\U0001F469\u200D\U0001F467\u200D\U0001F466": {"woman-woman-girl-boy", false},
+	"\U0001F469\u200D\U0001F469\u200D\U0001F466\u200D\U0001F466": {"woman-woman-boy-boy", false},
+	"\U0001F469\u200D\U0001F469\u200D\U0001F467\u200D\U0001F467": {"woman-woman-girl-girl", false},
+	"\U0001F468\u200D\U0001F466":                                 {"man-boy", false},
+	"\U0001F468\u200D\U0001F466\u200D\U0001F466":                 {"man-boy-boy", false},
+	"\U0001F468\u200D\U0001F467":                                 {"man-girl", false},
+	"\U0001F468\u200D\U0001F467\u200D\U0001F466":                 {"man-girl-boy", false},
+	"\U0001F468\u200D\U0001F467\u200D\U0001F467":                 {"man-girl-girl", false},
+	"\U0001F469\u200D\U0001F466":                                 {"woman-boy", false},
+	"\U0001F469\u200D\U0001F466\u200D\U0001F466":                 {"woman-boy-boy", false},
+	"\U0001F469\u200D\U0001F467":                                 {"woman-girl", false},
+	"\U0001F469\u200D\U0001F467\u200D\U0001F466":                 {"woman-girl-boy", false},
+	"\U0001F469\u200D\U0001F467\u200D\U0001F467":                 {"woman-girl-girl", false},
+	"\U0001F469\u200D\u2764\u200D\U0001F468":                     {"woman-heart-man", false},
+	"\U0001F469\u200D\u2764\u200D\U0001F48B\u200D\U0001F468":     {"woman-kiss-man", false},
+	"\U0001F468\u200D\u2764\u200D\U0001F468":                     {"man-heart-man", false},
+	"\U0001F468\u200D\u2764\u200D\U0001F48B\u200D\U0001F468":     {"man-kiss-man", false},
+	"\U0001F469\u200D\u2764\u200D\U0001F469":                     {"woman-heart-woman", false},
+	"\U0001F469\u200D\u2764\u200D\U0001F48B\u200D\U0001F469":     {"woman-kiss-woman", false},
+	"\U0001F468\u200D\u2695":                                     {"male-doctor", true},
+	"\U0001F469\u200D\u2695":                                     {"female-doctor", true},
+	"\U0001F468\u200D\u2696":                                     {"male-judge", true},
+	"\U0001F469\u200D\u2696":                                     {"female-judge", true},
+	"\U0001F468\u200D\u2708":                                     {"male-pilot", true},
+	"\U0001F469\u200D\u2708":                                     {"female-pilot", true},
+	"\U0001F468\u200D\U0001F33E":                                 {"male-farmer", true},
+	"\U0001F469\u200D\U0001F33E":                                 {"female-farmer", true},
+	"\U0001F468\u200D\U0001F527":                                 {"male-mechanic", true},
+	"\U0001F469\u200D\U0001F527":                                 {"female-mechanic", true},
+	"\U0001F468\u200D\U0001F3ED":                                 {"male-factory-worker", true},
+	"\U0001F469\u200D\U0001F3ED":                                 {"female-factory-worker", true},
+	"\U0001F468\u200D\U0001F4BC":                                 {"male-office-worker", true},
+	"\U0001F469\u200D\U0001F4BC":                                 {"female-office-worker", true},
+	"\U0001F468\u200D\U0001F3A4":                                 {"male-singer", true},
+	"\U0001F469\u200D\U0001F3A4":                                 {"female-singer", true},
+	"\U0001F468\u200D\U0001F3A8":                                 {"male-artist", true},
+	"\U0001F469\u200D\U0001F3A8":                                 {"female-artist", true},
+	"\U0001F468\u200D\U0001F692":                                 {"male-firefighter", true},
+	"\U0001F469\u200D\U0001F692":                                 {"female-firefighter", true},
+	"\U0001F64B\u200D\u2642":                                     {"man-raising-hand", true},
+	"\U0001F64B\u200D\u2640":                                     {"woman-raising-hand", true},
+	"\U0001F481\u200D\u2642":                                     {"man-tipping-hand", true},
+	"\U0001F481\u200D\u2640":                                     {"woman-tipping-hand", true},
+	"\U0001F645\u200D\u2642":                                     {"man-gesturing-no", true},
+	"\U0001F645\u200D\u2640":                                     {"woman-gesturing-no", true},
+	"\U0001F646\u200D\u2642":                                     {"man-gesturing-ok", true},
+	"\U0001F646\u200D\u2640":                                     {"woman-gesturing-ok", true},
+	"\U0001F647\u200D\u2642":                                     {"man-bowing", true},
+	"\U0001F647\u200D\u2640":                                     {"woman-bowing", true},
+	"\U0001F3C3\u200D\u2642":                                     {"man-running", true},
+	"\U0001F3C3\u200D\u2640":                                     {"woman-running", true},
+	"\U0001F6B6\u200D\u2642":                                     {"man-walking", true},
+	"\U0001F6B6\u200D\u2640":                                     {"woman-walking", true},
+	"\U0001F3CA\u200D\u2642":                                     {"man-swimming", true},
+	"\U0001F3CA\u200D\u2640":                                     {"woman-swimming", true},
+	"\U0001F6B4\u200D\u2642":                                     {"man-biking", true},
+	"\U0001F6B4\u200D\u2640":                                     {"woman-biking", true},
+	"\U0001F3C4\u200D\u2642":                                     {"man-surfing", true},
+	"\U0001F3C4\u200D\u2640":                                     {"woman-surfing", true},
+	"\U0001F6A3\u200D\u2642":                                     {"man-rowing-boat", true},
+	"\U0001F6A3\u200D\u2640":                                     {"woman-rowing-boat", true},
+	"\U0001F64D\u200D\u2642":                                     {"man-frowning", true},
+	"\U0001F64D\u200D\u2640":                                     {"woman-frowning", true},
+	"\U0001F64E\u200D\u2642":                                     {"man-pouting", true},
+	"\U0001F64E\u200D\u2640":                                     {"woman-pouting", true},
+	"\U0001F486\u200D\u2642":                                     {"man-getting-massage", true},
+	"\U0001F486\u200D\u2640":                                     {"woman-getting-massage", true},
+	"\U0001F487\u200D\u2642":                                     {"man-getting-haircut", true},
+	"\U0001F487\u200D\u2640":                                     {"woman-getting-haircut", true},
+	"\U0001F473\u200D\u2642":                                     {"man-wearing-turban", true},
+	"\U0001F473\u200D\u2640":                                     {"woman-wearing-turban", true},
+	"\U0001F3CB\u200D\u2642":                                     {"man-lifting-weights", true},
+	"\U0001F3CB\u200D\u2640":                                     {"woman-lifting-weights", true},
+	"\U0001F3CC\u200D\u2642":                                     {"man-golfing", true},
+	"\U0001F3CC\u200D\u2640":                                     {"woman-golfing", true},
+	"\U0001F46E\u200D\u2642":                                     {"male-police-officer", true},
+	"\U0001F46E\u200D\u2640":                                     {"female-police-officer", true},
+	"\U0001F482\u200D\u2642":                                     {"male-guard", true},
+	"\U0001F482\u200D\u2640":                                     {"female-guard", true},
+	"\U0001F477\u200D\u2642":                                     {"male-construction-worker", true},
+	"\U0001F477\u200D\u2640":                                     {"female-construction-worker", true},
+	"\U0001F575\u200D\u2642":                                     {"male-detective", true},
+	"\U0001F575\u200D\u2640":                                     {"female-detective", true},
+}
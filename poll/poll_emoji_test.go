@@ -0,0 +1,72 @@
+package poll_test
+
+import (
+	"fmt"
+	"net/url"
+	"testing"
+
+	"github.com/kaakaa/matterpoll-emoji/poll"
+	"github.com/mattermost/mattermost-server/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommandUnknownEmojis(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ps, mm := newTestServer(require)
+	defer mm.Close()
+	c := ps.Conf()
+
+	payload := fmt.Sprintf("token=%s&user_id=%s&channel_id=%s&text=\"Lunch?\" :piza: :sushi: :qwertyuiop:", c.Token, model.NewId(), model.NewId())
+	response, _ := sendHttpRequest(require, ps, payload)
+	assert.Equal(model.COMMAND_RESPONSE_TYPE_EPHEMERAL, response.ResponseType)
+	assert.Equal(fmt.Sprintf(poll.ErrorUnknownEmojis, "`:piza:` (did you mean `:pizza:`?), `:qwertyuiop:`"), response.Text)
+	assert.Len(mm.Posts(), 0)
+	polls, err := ps.Store.List()
+	require.Nil(err)
+	assert.Len(polls, 0)
+	assert.Equal(2, mm.EmojiLookups())
+
+	// Unknown emojis are looked up again, once they are added
+	mm.AddCustomEmoji("qwertyuiop")
+	response, _ = sendHttpRequest(require, ps, payload)
+	assert.Equal(fmt.Sprintf(poll.ErrorUnknownEmojis, "`:piza:` (did you mean `:pizza:`?), `:qwertyuiop:`"), response.Text)
+	assert.Equal(2, mm.EmojiLookups())
+}
+
+func TestCommandInvalidEmojiNames(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ps, mm := newTestServer(require)
+	defer mm.Close()
+	c := ps.Conf()
+
+	// Names which would change the path or the query of the lookup are rejected before anything is sent to Mattermost
+	for _, name := range []string{":../../users/me:", ":a?b=1:"} {
+		payload := fmt.Sprintf("token=%s&user_id=%s&channel_id=%s&text=\"Lunch?\" :pizza: %s", c.Token, model.NewId(), model.NewId(), url.QueryEscape(name))
+		response, _ := sendHttpRequest(require, ps, payload)
+		assert.Equal(fmt.Sprintf(poll.ErrorInvalidEmojiName, name), response.Text)
+	}
+	assert.Equal(0, mm.EmojiLookups())
+	assert.Equal(0, mm.Logins())
+	assert.Len(mm.Posts(), 0)
+}
+
+func TestCommandCustomEmojis(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ps, mm := newTestServer(require)
+	defer mm.Close()
+
+	mm.AddCustomEmoji("partyparrot")
+	for i := 0; i < 2; i++ {
+		createTestPoll(require, mm, ps, model.NewId(), ":partyparrot: :point_up_medium_skin_tone: :pizza:")
+	}
+	assert.Len(mm.Posts(), 2)
+	// System emojis aren't looked up and custom emojis only once
+	assert.Equal(1, mm.EmojiLookups())
+}
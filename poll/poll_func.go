@@ -1,116 +1,421 @@
 package poll
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/mattermost/mattermost-server/model"
 )
 
+// CommandPath is the path slash commands are sent to. The commands of an instance can also be sent to CommandPath + "/" + the instance name.
+const CommandPath = "/poll"
+
 const (
 	// ResponseUsername is the username which will be used to post the slack command response
 	ResponseUsername = "Matterpoll"
 	// ResponseIconURL is the profile picture which will be used to post the slack command response
 	ResponseIconURL = "https://www.mattermost.org/wp-content/uploads/2016/04/icon.png"
+	// ResponseTextCreated is the ephemeral message which is sent back to the user after the poll was posted. It is formatted with the poll id.
+	ResponseTextCreated = "Your poll has been posted. End it with `/poll end %s`"
+	// ResponseTextEnded is the ephemeral message which is sent back to the user after the poll was ended
+	ResponseTextEnded = "The poll has been ended and the results were posted."
+	// ErrorPostFailed is an error message and is used, if the poll couldn`t be posted to the channel
+	ErrorPostFailed = `An error occurred while posting the poll. Ask your administrator to check the Matterpoll logs.`
+	// ErrorEndFailed is an error message and is used, if the poll couldn`t be ended
+	ErrorEndFailed = `An error occurred while ending the poll. Ask your administrator to check the Matterpoll logs.`
+	// ErrorPollNotFound is an error message and is used, if no poll with the requested id exists
+	ErrorPollNotFound = `There is no poll with this ID.`
+	// ErrorNotCreator is an error message and is used, if somebody other than the creator tries to end a poll
+	ErrorNotCreator = `Only the creator of a poll can end it.`
+	// ErrorPollClosed is an error message and is used, if the poll has already been ended
+	ErrorPollClosed = `This poll has already been ended.`
+	// ErrorAnonymousDisabled is an error message and is used, if an anonymous poll is requested but the Matterpoll URL isn`t configured
+	ErrorAnonymousDisabled = `Anonymous polls are disabled. Ask your administrator to set the Matterpoll ` + "`url`" + ` in the config.`
+	// ErrorUnknownEmojis is an error message and is used, if an option of a poll is neither a system emoji nor a custom emoji. It is formatted with the unknown emojis.
+	ErrorUnknownEmojis = `These emojis don't exist: %s. Check the names of the emojis and try again.`
+	// ErrorAnonymousNotAllowed is an error message and is used, if an anonymous poll is requested but anonymous polls are disabled for the team
+	ErrorAnonymousNotAllowed = `Anonymous polls are disabled for this team.`
 )
 
 // Server handles slash commands from a mattermost instance. One sever may handle multiple requests from one mattermost instance. It uses a provided configuration to handle the requests.
 type Server struct {
-	Conf *Conf
+	Store Storage
+
+	// conf holds the current *Conf. It is replaced by SetConf while requests are handled.
+	conf atomic.Value
+	// reconnect notifies the websocket connections that the Mattermost servers or the bot credentials changed
+	reconnect chan struct{}
+
+	// pollLocksMu guards pollLocks, which serialize changes to one stored poll, e.g. to make sure a poll is only closed once
+	pollLocksMu sync.Mutex
+	pollLocks   map[string]*pollLock
+	// wakeup notifies the scheduler about a new deadline
+	wakeup   chan struct{}
+	stop     chan struct{}
+	stopOnce sync.Once
+	// jobs tracks the background work, which is waited for by Shutdown
+	jobs jobTracker
+
+	// sessionsMu guards sessions, the logins of the bot users by instance name
+	sessionsMu sync.Mutex
+	sessions   map[string]*session
+
+	// refreshMu guards the pending refreshes of poll posts and the last rendered messages
+	refreshMu sync.Mutex
+	refreshes map[string]*pendingRefresh
+	rendered  map[string]string
+
+	// emojiMu guards emojis, the looked up custom emojis by instance and emoji name
+	emojiMu sync.Mutex
+	emojis  map[string]emojiCacheEntry
+
+	metrics *metrics
+
+	// logger is the current *Logger. All loggers write to logOutput.
+	logger    atomic.Value
+	logOutput *logOutput
+
+	// readyMu guards ready, the last result of the readiness check, and readyCheck, which is closed when the running check is done.
+	// It isn't held while Mattermost is checked.
+	readyMu    sync.Mutex
+	ready      *readiness
+	readyCheck chan struct{}
+}
+
+// pollLock is the lock of one poll. It is dropped when nobody holds or waits for it.
+type pollLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+// lockPoll locks the poll with id and returns the function which unlocks it. Changes to other polls aren't blocked.
+func (ps *Server) lockPoll(id string) func() {
+	ps.pollLocksMu.Lock()
+	l, ok := ps.pollLocks[id]
+	if !ok {
+		l = &pollLock{}
+		ps.pollLocks[id] = l
+	}
+	l.refs++
+	ps.pollLocksMu.Unlock()
+
+	l.mu.Lock()
+	return func() {
+		l.mu.Unlock()
+		ps.pollLocksMu.Lock()
+		defer ps.pollLocksMu.Unlock()
+		l.refs--
+		if l.refs == 0 {
+			delete(ps.pollLocks, id)
+		}
+	}
+}
+
+// NewServer creates a Server for the configuration c and sets up the configured poll store
+func NewServer(c *Conf) (*Server, error) {
+	store, err := NewStorage(&c.Store)
+	if err != nil {
+		return nil, err
+	}
+	ps := &Server{
+		Store:     store,
+		reconnect: make(chan struct{}, 1),
+		sessions:  make(map[string]*session),
+		pollLocks: make(map[string]*pollLock),
+		wakeup:    make(chan struct{}, 1),
+		stop:      make(chan struct{}),
+		refreshes: make(map[string]*pendingRefresh),
+		rendered:  make(map[string]string),
+		emojis:    make(map[string]emojiCacheEntry),
+		metrics:   newMetrics(),
+		logOutput: &logOutput{w: os.Stderr},
+	}
+	ps.conf.Store(c)
+	ps.updateLogger(c)
+	return ps, nil
+}
+
+// Start starts the background work of the server. Polls with a deadline are closed when the deadline has passed, including polls created before a restart.
+// Reactions on polls are watched through a websocket connection to enforce single choice polls and to refresh the results in the poll posts.
+func (ps *Server) Start() {
+	ps.goJob("poll scheduler", ps.runScheduler)
+	ps.goJob("websocket connection", ps.runWebSocket)
+	ps.goJob("periodic refresh", ps.runRefresher)
+}
+
+// Stop stops the background work started by Start and waits until running work, e.g. adding reactions to a new poll, is finished
+func (ps *Server) Stop() {
+	ps.Shutdown(context.Background())
 }
 
-// Cmd handles a slash command request and sends back a response
-func (ps Server) Cmd(w http.ResponseWriter, r *http.Request) {
+// Shutdown stops the background work started by Start. Pending refreshes of poll posts are done immediately.
+// It waits until all background work is finished or ctx is done. Work which couldn't finish is logged and ctx.Err() is returned.
+func (ps *Server) Shutdown(ctx context.Context) error {
+	ps.stopOnce.Do(func() {
+		close(ps.stop)
+	})
+	ps.flushRefreshes()
+	unfinished := ps.jobs.wait(ctx)
+	for _, name := range unfinished {
+		ps.Logger().Error("Shutdown before work finished", "job", name)
+	}
+	if len(unfinished) != 0 {
+		return ctx.Err()
+	}
+	return nil
+}
+
+// Cmd handles a slash command request and sends back a response. The request is handled by the instance named in the path after CommandPath.
+// If the path doesn't name an instance, the instance is chosen by the token of the request.
+func (ps *Server) Cmd(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
 	// Check if Content Type is correct
 	if r.Header.Get("Content-Type") != "application/x-www-form-urlencoded" {
+		ps.metrics.countCommand("unknown", outcomeBadMediaType)
 		w.WriteHeader(http.StatusUnsupportedMediaType)
 		return
 	}
 	err := r.ParseForm()
 	if err != nil {
+		ps.metrics.countCommand("unknown", outcomeWrongFormat)
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
-	poll, err := NewRequest(r.Form)
-	validPoll := err == nil
 
-	var response model.CommandResponse
-	response.Username = ResponseUsername
-	response.IconURL = ResponseIconURL
-
-	if validPoll && len(ps.Conf.Token) != 0 && ps.Conf.Token != poll.Token {
-		validPoll = false
-		err = fmt.Errorf(ErrorTokenMissmatch)
-	}
-	if validPoll {
-		response.ResponseType = model.COMMAND_RESPONSE_TYPE_IN_CHANNEL
-		response.Text = poll.Message + ` #poll`
-	} else {
-		response.ResponseType = model.COMMAND_RESPONSE_TYPE_EPHEMERAL
-		response.Text = err.Error()
-	}
+	instance := strings.Trim(strings.TrimPrefix(r.URL.Path, CommandPath), "/")
+	lg := ps.Logger().With("request_id", model.NewId(), "instance", instance)
+	response := ps.dispatch(lg, r.Form, instance)
 
 	w.Header().Add("Content-Type", "application/json")
 	if _, err := io.WriteString(w, response.ToJson()); err != nil {
-		log.Print(err)
-		return
-	}
-	if validPoll {
-		c := model.NewAPIv4Client(ps.Conf.Host)
-		user, err := ps.login(c)
-		if err != nil {
-			log.Print(err)
-			return
-		}
-		go ps.addReaction(c, user, poll)
+		lg.Warn("Failed to send response", "err", err)
 	}
 }
 
-func (ps Server) login(c *model.Client4) (*model.User, error) {
-	u, apiResponse := c.Login(ps.Conf.User.ID, ps.Conf.User.Password)
-	if apiResponse != nil && apiResponse.StatusCode != 200 {
-		return nil, fmt.Errorf("Error: Login failed. API statuscode: %v", apiResponse.StatusCode)
+// create posts the poll requested in form. It returns the answer and the outcome of the command.
+func (ps *Server) create(lg *Logger, form url.Values, instance string) (*model.CommandResponse, string) {
+	start := time.Now()
+	poll, err := NewRequest(form)
+	if err != nil {
+		return requestError(err)
+	}
+	in, team, err := ps.checkToken(instance, poll.Token, poll.TeamID)
+	if err != nil {
+		return requestError(err)
+	}
+	if poll.Anonymous && len(ps.Conf().URL) == 0 {
+		return newResponse(ErrorAnonymousDisabled), outcomeRejected
+	}
+	if poll.Anonymous && team.DisableAnonymous {
+		return newResponse(ErrorAnonymousNotAllowed), outcomeRejected
+	}
+	if poll.Until.IsZero() && team.DefaultDuration > 0 {
+		poll.Until = time.Now().Add(time.Duration(team.DefaultDuration))
 	}
-	return u, nil
-}
 
-func (ps Server) addReaction(c *model.Client4, user *model.User, poll *Request) {
-	for try := 0; try < 5; try++ {
-		// Get the last post and compare it to our message text
-		result, apiResponse := c.GetPostsForChannel(poll.ChannelID, 0, 1, "")
-		if apiResponse != nil && apiResponse.StatusCode != 200 {
-			log.Printf("Error: Failed to fetch posts. API statuscode: %v", apiResponse.StatusCode)
-			return
+	p := newPoll(poll)
+	p.Instance = in.Name
+	lg = lg.With("poll_id", p.ID)
+	err = ps.withClient(lg, p.Instance, func(c *model.Client4, bot *model.User) error {
+		if err := ps.validateEmojis(lg, c, p.Instance, p.Options); err != nil {
+			return err
 		}
-		postID := result.Order[0]
-		if result.Posts[postID].Message == poll.Message+" #poll" {
-			err := reaction(c, poll.ChannelID, user.Id, postID, poll.Emojis)
-			if err != nil {
-				log.Print(err)
-				return
+		if err := ps.createPost(c, p); err != nil {
+			return err
+		}
+		if err := ps.Store.Save(p); err != nil {
+			// Without its record the poll could neither be ended nor counted, so the post is removed again
+			_, apiResponse := c.DeletePost(p.PostID)
+			if err := checkResponse(apiResponse, http.StatusOK, "Failed to delete poll post"); err != nil {
+				lg.Warn("Failed to remove the post of the unsaved poll", "post_id", p.PostID, "err", err)
 			}
-			return
+			return err
 		}
-		// Try again later
-		time.Sleep(100 * time.Millisecond)
+		return nil
+	})
+	if e, ok := err.(*unknownEmojiError); ok {
+		return newResponse(e.Error()), outcomeUnknownEmoji
+	}
+	if err != nil {
+		lg.Error("Failed to post poll", "err", err)
+		return newResponse(ErrorPostFailed), outcomeFailed
 	}
+	lg.Info("Poll posted", "post_id", p.PostID, "options", len(p.Options))
+	if !p.ExpiresAt.IsZero() {
+		ps.reschedule()
+	}
+	if !p.Anonymous {
+		ps.goJob("adding reactions to poll "+p.ID, func() { ps.addReaction(lg, p, start) })
+	}
+	return newResponse(fmt.Sprintf(ResponseTextCreated, p.ID)), outcomeCreated
 }
 
-func reaction(c *model.Client4, channelID string, userID string, postID string, emojis []string) error {
-	for _, e := range emojis {
-		r := model.Reaction{
-			UserId:    userID,
-			PostId:    postID,
-			EmojiName: e,
-		}
-		_, apiResponse := c.SaveReaction(&r)
-		if apiResponse != nil && apiResponse.StatusCode != 200 {
-			return fmt.Errorf("Error: Failed to save reaction. API statuscode: %v", apiResponse.StatusCode)
-		}
+// end closes the poll requested in form and posts its results. It returns the answer and the outcome of the command.
+func (ps *Server) end(lg *Logger, form url.Values, instance string) (*model.CommandResponse, string) {
+	req, err := NewEndRequest(form)
+	if err != nil {
+		return requestError(err)
+	}
+	in, _, err := ps.checkToken(instance, req.Token, req.TeamID)
+	if err != nil {
+		return requestError(err)
 	}
+
+	lg = lg.With("poll_id", req.PollID)
+	p, err := ps.Store.Get(req.PollID)
+	if err == ErrPollNotFound || (err == nil && p.Instance != in.Name) {
+		lg.Debug("Poll not found")
+		return newResponse(ErrorPollNotFound), outcomeNotFound
+	}
+	if err != nil {
+		lg.Error("Failed to look up poll", "err", err)
+		return newResponse(ErrorEndFailed), outcomeFailed
+	}
+	if p.Creator != req.UserID {
+		return newResponse(ErrorNotCreator), outcomeRejected
+	}
+	if p.State == PollStateClosed {
+		return newResponse(ErrorPollClosed), outcomeRejected
+	}
+
+	err = ps.withClient(lg, p.Instance, func(c *model.Client4, bot *model.User) error {
+		return ps.closePoll(lg, c, bot.Id, p.ID)
+	})
+	if err == errPollClosed {
+		return newResponse(ErrorPollClosed), outcomeRejected
+	}
+	if err != nil {
+		lg.Error("Failed to end poll", "err", err)
+		return newResponse(ErrorEndFailed), outcomeFailed
+	}
+	return newResponse(ResponseTextEnded), outcomeEnded
+}
+
+// checkToken verifies that a slash command was sent with one of the configured tokens. If instance is not empty, the token must belong to this instance.
+// It returns the instance and the settings of the team the token belongs to.
+func (ps *Server) checkToken(instance string, token string, teamID string) (*Instance, *TeamSettings, error) {
+	in, t, ok := ps.Conf().commandToken(instance, token, teamID)
+	if !ok {
+		return nil, nil, errTokenMismatch
+	}
+	return in, &t.TeamSettings, nil
+}
+
+// errTokenMismatch is returned by checkToken, if the token isn't configured
+var errTokenMismatch = errors.New(ErrorTokenMissmatch)
+
+// requestError answers a slash command which couldn't be parsed or has a wrong token with err and returns the outcome of the command
+func requestError(err error) (*model.CommandResponse, string) {
+	if err == errTokenLength || err == errTokenMismatch {
+		return newResponse(err.Error()), outcomeTokenMismatch
+	}
+	return newResponse(err.Error()), outcomeWrongFormat
+}
+
+// newResponse creates an ephemeral slash command response with text
+func newResponse(text string) *model.CommandResponse {
+	return &model.CommandResponse{
+		ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+		Username:     ResponseUsername,
+		IconURL:      ResponseIconURL,
+		Text:         text,
+	}
+}
+
+// newPoll creates the record of a poll requested by poll
+func newPoll(poll *Request) *Poll {
+	p := &Poll{
+		ID:        model.NewId(),
+		Creator:   poll.UserID,
+		ChannelID: poll.ChannelID,
+		RootID:    poll.RootID,
+		Question:  poll.Message,
+		Options:   poll.Emojis,
+		Labels:    poll.Labels,
+		CreatedAt: time.Now(),
+		ExpiresAt: poll.Until,
+		Single:    poll.Single,
+		Anonymous: poll.Anonymous,
+		State:     PollStateOpen,
+	}
+	if p.Anonymous {
+		p.Secret = model.NewId()
+		p.Votes = make(map[string]string)
+	}
+	return p
+}
+
+// createPost posts the poll to its channel. The id of the created post is set in p.
+func (ps *Server) createPost(c *model.Client4, p *Poll) error {
+	post, apiResponse := createPost(c, ps.pollPost(p, countVotes(p, nil, "")))
+	if err := checkResponse(apiResponse, http.StatusCreated, "Failed to create post"); err != nil {
+		return err
+	}
+	p.PostID = post.Id
+	ps.setRendered(p, post.Message)
 	return nil
 }
+
+// postMessage renders the text of the poll post with a bar chart of results
+func postMessage(p *Poll, results []Result) string {
+	message := p.Question + ` #poll`
+	if legend := formatLegend(p); len(legend) != 0 {
+		message += "\n\n" + legend
+	}
+	message += "\n\n" + formatTally(results)
+	if p.State == PollStateClosed {
+		message += "\n_This poll has ended._"
+	} else if !p.ExpiresAt.IsZero() {
+		message += "\n_This poll ends at " + p.ExpiresAt.Format("2006-01-02 15:04 MST") + "_"
+	}
+	return message
+}
+
+// formatLegend lists the labels of the options of p. It is empty, if the options have no labels.
+func formatLegend(p *Poll) string {
+	var b bytes.Buffer
+	for i, label := range p.Labels {
+		if len(label) != 0 {
+			fmt.Fprintf(&b, ":%s: %s\n", p.Options[i], label)
+		}
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// pollPost renders the post of p showing results. Anonymous polls get a button for every option.
+func (ps *Server) pollPost(p *Poll, results []Result) *model.Post {
+	post := &model.Post{
+		ChannelId: p.ChannelID,
+		RootId:    p.RootID,
+		Message:   postMessage(p, results),
+	}
+	if p.Anonymous {
+		post.AddProp("attachments", ps.voteAttachments(p, results))
+	}
+	return post
+}
+
+// createPost creates post like Client4.CreatePost, but keeps the integrations of the post actions
+func createPost(c *model.Client4, post *model.Post) (*model.Post, *model.Response) {
+	b, err := json.Marshal(post)
+	if err != nil {
+		return nil, &model.Response{Error: model.NewAppError("createPost", "model.utils.encode_json.app_error", nil, err.Error(), 0)}
+	}
+	r, appErr := c.DoApiPost(c.GetPostsRoute(), string(b))
+	if appErr != nil {
+		return nil, model.BuildErrorResponse(r, appErr)
+	}
+	defer r.Body.Close()
+	return model.PostFromJson(r.Body), model.BuildResponse(r)
+}
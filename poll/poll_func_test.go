@@ -1,11 +1,13 @@
 package poll_test
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/kaakaa/matterpoll-emoji/poll"
 	"github.com/mattermost/mattermost-server/model"
@@ -19,18 +21,162 @@ func TestCommandCorrect(t *testing.T) {
 
 	message := "What do you gys wanna grab for lunch?"
 	emojis := ":pizza: :sushi:"
-	c, err := getConfig("sample_conf.json")
-	require.Nil(err)
-	ps := poll.Server{Conf: c}
+	ps, mm := newTestServer(require)
+	defer mm.Close()
+	c := ps.Conf()
 
-	payload := fmt.Sprintf("token=%s&channel_id=%s&text=\"%s\"%s", c.Token, model.NewId(), message, emojis)
-	response, header := sendHttpRequest(require, &ps, payload)
+	userID, channelID := model.NewId(), model.NewId()
+	payload := fmt.Sprintf("token=%s&user_id=%s&channel_id=%s&text=\"%s\"%s", c.Token, userID, channelID, message, emojis)
+	response, header := sendHttpRequest(require, ps, payload)
 
 	assert.Equal("application/json", header.Get("Content-Type"))
 	assert.Equal(poll.ResponseUsername, response.Username)
 	assert.Equal(poll.ResponseIconURL, response.IconURL)
-	assert.Equal(model.COMMAND_RESPONSE_TYPE_IN_CHANNEL, response.ResponseType)
-	assert.Equal(message+" #poll", response.Text)
+	assert.Equal(model.COMMAND_RESPONSE_TYPE_EPHEMERAL, response.ResponseType)
+
+	posts := mm.Posts()
+	require.Len(posts, 1)
+	assert.Equal(channelID, posts[0].ChannelId)
+	assert.True(strings.HasPrefix(posts[0].Message, message+" #poll\n"))
+	assert.Contains(posts[0].Message, ":pizza: `░░░░░░░░░░` 0 (0%)")
+	assert.True(waitFor(func() bool { return len(mm.Reactions(posts[0].Id)) == 2 }))
+	assert.Equal([]string{"pizza", "sushi"}, mm.Reactions(posts[0].Id))
+
+	polls, err := ps.Store.List()
+	require.Nil(err)
+	require.Len(polls, 1)
+	assert.Equal(userID, polls[0].Creator)
+	assert.Equal(channelID, polls[0].ChannelID)
+	assert.Equal(posts[0].Id, polls[0].PostID)
+	assert.Equal(message, polls[0].Question)
+	assert.Equal([]string{"pizza", "sushi"}, polls[0].Options)
+	assert.Equal(poll.PollStateOpen, polls[0].State)
+	assert.Equal(fmt.Sprintf(poll.ResponseTextCreated, polls[0].ID), response.Text)
+}
+
+func TestCommandLabels(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ps, mm := newTestServer(require)
+	defer mm.Close()
+	c := ps.Conf()
+
+	creator := model.NewId()
+	payload := fmt.Sprintf("token=%s&user_id=%s&channel_id=%s&text=\"Where?\" :one: \"Thai place\" :two: \"Burger bar\"", c.Token, creator, model.NewId())
+	sendHttpRequest(require, ps, payload)
+
+	posts := mm.Posts()
+	require.Len(posts, 1)
+	assert.True(strings.HasPrefix(posts[0].Message, "Where? #poll\n\n:one: Thai place\n:two: Burger bar\n\n:one: `"))
+	assert.True(waitFor(func() bool { return len(mm.Reactions(posts[0].Id)) == 2 }))
+	assert.Equal([]string{"one", "two"}, mm.Reactions(posts[0].Id))
+
+	polls, err := ps.Store.List()
+	require.Nil(err)
+	require.Len(polls, 1)
+	assert.Equal([]string{"one", "two"}, polls[0].Options)
+	assert.Equal([]string{"Thai place", "Burger bar"}, polls[0].Labels)
+
+	// The results name the labels as well
+	mm.AddReaction(posts[0].Id, model.NewId(), "two")
+	payload = fmt.Sprintf("token=%s&user_id=%s&text=end %s", c.Token, creator, polls[0].ID)
+	sendHttpRequest(require, ps, payload)
+	posts = mm.Posts()
+	require.Len(posts, 2)
+	assert.Contains(posts[1].Message, "| :one: Thai place | 0 | 0% |")
+	assert.Contains(posts[1].Message, "| :two: Burger bar | 1 | 100% |")
+
+	// So do the buttons of anonymous polls
+	c.URL = "http://matterpoll.example.com"
+	payload = fmt.Sprintf("token=%s&user_id=%s&channel_id=%s&text=\"Where?\" :one: \"Thai place\" :two: --anonymous", c.Token, creator, model.NewId())
+	sendHttpRequest(require, ps, payload)
+	posts = mm.Posts()
+	require.Len(posts, 3)
+	attachments := posts[2].Attachments()
+	require.Len(attachments, 1)
+	require.Len(attachments[0].Actions, 2)
+	assert.Equal(":one: Thai place 0", attachments[0].Actions[0].Name)
+	assert.Equal(":two: 0", attachments[0].Actions[1].Name)
+}
+
+func TestCommandInThread(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ps, mm := newTestServer(require)
+	defer mm.Close()
+	c := ps.Conf()
+
+	creator, channelID, rootID := model.NewId(), model.NewId(), model.NewId()
+	payload := fmt.Sprintf("token=%s&user_id=%s&channel_id=%s&root_id=%s&text=\"Lunch?\" :pizza: :sushi:", c.Token, creator, channelID, rootID)
+	sendHttpRequest(require, ps, payload)
+
+	posts := mm.Posts()
+	require.Len(posts, 1)
+	assert.Equal(channelID, posts[0].ChannelId)
+	assert.Equal(rootID, posts[0].RootId)
+
+	polls, err := ps.Store.List()
+	require.Nil(err)
+	require.Len(polls, 1)
+	assert.Equal(rootID, polls[0].RootID)
+
+	// The results are posted to the same thread
+	payload = fmt.Sprintf("token=%s&user_id=%s&text=end %s", c.Token, creator, polls[0].ID)
+	response, _ := sendHttpRequest(require, ps, payload)
+	assert.Equal(poll.ResponseTextEnded, response.Text)
+	posts = mm.Posts()
+	require.Len(posts, 2)
+	assert.Equal(rootID, posts[1].RootId)
+}
+
+func TestCommandPostFailed(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	mm := newFakeMattermost()
+	c, err := getConfig("sample_conf.json")
+	require.Nil(err)
+	c.Host = mm.URL
+	mm.Close()
+	ps, err := poll.NewServer(c)
+	require.Nil(err)
+
+	payload := fmt.Sprintf("token=%s&user_id=%s&channel_id=%s&text=\"%s\"%s", c.Token, model.NewId(), model.NewId(), "Lunch?", ":pizza:")
+	response, _ := sendHttpRequest(require, ps, payload)
+
+	assert.Equal(model.COMMAND_RESPONSE_TYPE_EPHEMERAL, response.ResponseType)
+	assert.Equal(poll.ErrorPostFailed, response.Text)
+}
+
+func TestCommandSaveFailed(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ps, mm := newTestServer(require)
+	defer mm.Close()
+	c := ps.Conf()
+	ps.Store = failingStorage{poll.NewMemoryStorage()}
+
+	payload := fmt.Sprintf("token=%s&user_id=%s&channel_id=%s&text=\"%s\"%s", c.Token, model.NewId(), model.NewId(), "Lunch?", ":pizza:")
+	response, _ := sendHttpRequest(require, ps, payload)
+
+	// The poll can't be ended without its record, so its id isn't shown and the post is removed
+	assert.Equal(poll.ErrorPostFailed, response.Text)
+	assert.Len(mm.Posts(), 0)
+	polls, err := ps.Store.List()
+	require.Nil(err)
+	assert.Len(polls, 0)
+}
+
+// failingStorage is a Storage which can't save polls
+type failingStorage struct {
+	*poll.MemoryStorage
+}
+
+func (failingStorage) Save(p *poll.Poll) error {
+	return errors.New("disk full")
 }
 
 func TestCommandWronMessageFormat(t *testing.T) {
@@ -41,10 +187,11 @@ func TestCommandWronMessageFormat(t *testing.T) {
 	emojis := ""
 	c, err := getConfig("sample_conf.json")
 	require.Nil(err)
-	ps := poll.Server{Conf: c}
+	ps, err := poll.NewServer(c)
+	require.Nil(err)
 
-	payload := fmt.Sprintf("token=%s&channel_id=%s&text=\"%s\"%s", model.NewId(), model.NewId(), message, emojis)
-	response, header := sendHttpRequest(require, &ps, payload)
+	payload := fmt.Sprintf("token=%s&user_id=%s&channel_id=%s&text=\"%s\"%s", model.NewId(), model.NewId(), model.NewId(), message, emojis)
+	response, header := sendHttpRequest(require, ps, payload)
 
 	assert.Equal("application/json", header.Get("Content-Type"))
 	assert.Equal(poll.ResponseUsername, response.Username)
@@ -61,10 +208,11 @@ func TestCommandTokenMissmatch(t *testing.T) {
 	emojis := ":pizza: :sushi:"
 	c, err := getConfig("sample_conf.json")
 	require.Nil(err)
-	ps := poll.Server{Conf: c}
+	ps, err := poll.NewServer(c)
+	require.Nil(err)
 
-	payload := fmt.Sprintf("token=%s&channel_id=%s&text=\"%s\"%s", model.NewId(), model.NewId(), message, emojis)
-	response, header := sendHttpRequest(require, &ps, payload)
+	payload := fmt.Sprintf("token=%s&user_id=%s&channel_id=%s&text=\"%s\"%s", model.NewId(), model.NewId(), model.NewId(), message, emojis)
+	response, header := sendHttpRequest(require, ps, payload)
 
 	assert.Equal("application/json", header.Get("Content-Type"))
 	assert.Equal(poll.ResponseUsername, response.Username)
@@ -81,9 +229,10 @@ func TestHeaderMediaTypeWrong(t *testing.T) {
 	emojis := ":pizza: :sushi:"
 	c, err := getConfig("sample_conf.json")
 	require.Nil(err)
-	ps := poll.Server{Conf: c}
+	ps, err := poll.NewServer(c)
+	require.Nil(err)
 
-	payload := fmt.Sprintf("token=%s&channel_id=%s&text=\"%s\"%s", c.Token, model.NewId(), message, emojis)
+	payload := fmt.Sprintf("token=%s&user_id=%s&channel_id=%s&text=\"%s\"%s", c.Token, model.NewId(), model.NewId(), message, emojis)
 	reader := strings.NewReader(payload)
 	r, err := http.NewRequest("POST", "localhost:8505/poll", reader)
 	require.Nil(err)
@@ -100,7 +249,8 @@ func TestURLFormat(t *testing.T) {
 
 	c, err := getConfig("sample_conf.json")
 	require.Nil(err)
-	ps := poll.Server{Conf: c}
+	ps, err := poll.NewServer(c)
+	require.Nil(err)
 
 	payload := "%"
 	reader := strings.NewReader(payload)
@@ -127,9 +277,13 @@ func getConfig(path string) (*poll.Conf, error) {
 }
 
 func sendHttpRequest(require *require.Assertions, ps *poll.Server, payload string) (response *model.CommandResponse, header http.Header) {
+	return sendHttpRequestTo(require, ps, "localhost:8505/poll", payload)
+}
+
+func sendHttpRequestTo(require *require.Assertions, ps *poll.Server, url string, payload string) (response *model.CommandResponse, header http.Header) {
 	reader := strings.NewReader(payload)
 
-	r, err := http.NewRequest(http.MethodPost, "localhost:8505/poll", reader)
+	r, err := http.NewRequest(http.MethodPost, url, reader)
 	require.Nil(err)
 	require.NotNil(r)
 	r.Header.Add("Content-Type", "application/x-www-form-urlencoded")
@@ -141,3 +295,47 @@ func sendHttpRequest(require *require.Assertions, ps *poll.Server, payload strin
 	require.NotNil(response)
 	return
 }
+
+func TestCommandTeamTokens(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	mm := newFakeMattermost()
+	defer mm.Close()
+	c, err := getConfig("sample_conf_tokens.json")
+	require.Nil(err)
+	c.Host = mm.URL
+	c.URL = "http://localhost:8505"
+	ps, err := poll.NewServer(c)
+	require.Nil(err)
+
+	teamToken, teamID := c.Tokens[0].Token, c.Tokens[0].TeamID
+	send := func(token, teamID, options string) string {
+		payload := fmt.Sprintf("token=%s&team_id=%s&user_id=%s&channel_id=%s&text=\"%s\" %s", token, teamID, model.NewId(), model.NewId(), "Lunch?", ":pizza: "+options)
+		response, _ := sendHttpRequest(require, ps, payload)
+		return response.Text
+	}
+
+	// The token is tied to its team
+	assert.Equal(poll.ErrorTokenMissmatch, send(teamToken, model.NewId(), ""))
+	assert.Equal(poll.ErrorTokenMissmatch, send(model.NewId(), teamID, ""))
+	assert.Len(mm.Posts(), 0)
+
+	// The settings of the team are used
+	assert.Equal(poll.ErrorAnonymousNotAllowed, send(teamToken, teamID, "--anonymous"))
+	before := time.Now()
+	send(teamToken, teamID, "")
+	polls, err := ps.Store.List()
+	require.Nil(err)
+	require.Len(polls, 1)
+	assert.WithinDuration(before.Add(24*time.Hour), polls[0].ExpiresAt, time.Minute)
+
+	// Tokens without a team accept requests from any team and use the default settings
+	send(c.Tokens[1].Token, model.NewId(), "--anonymous")
+	send(c.Token, model.NewId(), "")
+	polls, err = ps.Store.List()
+	require.Nil(err)
+	require.Len(polls, 3)
+	assert.True(polls[1].Anonymous)
+	assert.True(polls[2].ExpiresAt.IsZero())
+}
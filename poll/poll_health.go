@@ -0,0 +1,162 @@
+package poll
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/mattermost/mattermost-server/model"
+)
+
+const (
+	// HealthzPath is the path of the liveness check. It succeeds as long as Matterpoll answers requests.
+	HealthzPath = "/healthz"
+	// ReadyzPath is the path of the readiness check. It succeeds, if every Mattermost server is reachable and its bot user can authenticate.
+	ReadyzPath = "/readyz"
+)
+
+// readyCacheTTL is the time the result of the readiness check is reused, so that frequent probes don't burden the Mattermost servers
+const readyCacheTTL = 5 * time.Second
+
+// checkTimeout is the time to wait for the answer of a Mattermost server to each check, unless api_timeout is shorter
+const checkTimeout = 5 * time.Second
+
+// readiness is the result of the readiness check
+type readiness struct {
+	Ready     bool                `json:"ready"`
+	Instances []instanceReadiness `json:"instances"`
+	// checked is the time the checks were run
+	checked time.Time
+}
+
+// instanceReadiness is the result of the readiness check of one instance
+type instanceReadiness struct {
+	Name string      `json:"name"`
+	Host string      `json:"host"`
+	Ping checkResult `json:"ping"`
+	Auth checkResult `json:"auth"`
+}
+
+// checkResult is the result of a single check. Error is empty, if the check succeeded.
+type checkResult struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+func newCheckResult(err error) checkResult {
+	if err != nil {
+		return checkResult{Error: err.Error()}
+	}
+	return checkResult{OK: true}
+}
+
+// Healthz answers the liveness check
+func (ps *Server) Healthz(w http.ResponseWriter, r *http.Request) {
+	ps.writeHealth(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// Readyz answers the readiness check with the results of the checks of every instance.
+// It responds with 503 Service Unavailable, if a check failed.
+func (ps *Server) Readyz(w http.ResponseWriter, r *http.Request) {
+	result := ps.readiness()
+	status := http.StatusOK
+	if !result.Ready {
+		status = http.StatusServiceUnavailable
+	}
+	ps.writeHealth(w, status, result)
+}
+
+// readiness checks every instance. The result is cached for readyCacheTTL.
+// Concurrent calls share one check, so that frequent probes don't pile up while Mattermost is slow.
+func (ps *Server) readiness() *readiness {
+	ps.readyMu.Lock()
+	if ps.ready != nil && time.Since(ps.ready.checked) < readyCacheTTL {
+		defer ps.readyMu.Unlock()
+		return ps.ready
+	}
+	if wait := ps.readyCheck; wait != nil {
+		ps.readyMu.Unlock()
+		<-wait
+		ps.readyMu.Lock()
+		defer ps.readyMu.Unlock()
+		return ps.ready
+	}
+	done := make(chan struct{})
+	ps.readyCheck = done
+	ps.readyMu.Unlock()
+
+	result := ps.checkInstances()
+
+	ps.readyMu.Lock()
+	defer ps.readyMu.Unlock()
+	ps.ready = result
+	ps.readyCheck = nil
+	close(done)
+	return result
+}
+
+// checkInstances checks all instances at the same time, so that a slow Mattermost server doesn't delay the checks of the others
+func (ps *Server) checkInstances() *readiness {
+	instances := ps.Conf().instances()
+	result := &readiness{Ready: true, checked: time.Now(), Instances: make([]instanceReadiness, len(instances))}
+	var wg sync.WaitGroup
+	for i, in := range instances {
+		wg.Add(1)
+		go func(i int, in Instance) {
+			defer wg.Done()
+			result.Instances[i] = ps.checkInstance(in)
+		}(i, in)
+	}
+	wg.Wait()
+	for _, ir := range result.Instances {
+		result.Ready = result.Ready && ir.Ping.OK && ir.Auth.OK
+	}
+	return result
+}
+
+// checkInstance pings the Mattermost server of in and verifies that its bot user can authenticate
+func (ps *Server) checkInstance(in Instance) instanceReadiness {
+	timeout := checkTimeout
+	if t := time.Duration(ps.Conf().APITimeout); t < timeout {
+		timeout = t
+	}
+	ir := instanceReadiness{Name: in.Name, Host: in.Host}
+	c := model.NewAPIv4Client(in.Host)
+	c.HttpClient = &http.Client{Timeout: timeout}
+	_, apiResponse := c.GetPing()
+	ir.Ping = newCheckResult(checkResponse(apiResponse, http.StatusOK, "Failed to ping Mattermost"))
+	if !ir.Ping.OK {
+		ir.Auth = checkResult{Error: "Skipped, because Mattermost is unreachable"}
+		return ir
+	}
+	ir.Auth = newCheckResult(ps.checkAuth(in.Name, timeout))
+	return ir
+}
+
+// checkAuth verifies that the bot user of the instance called name can authenticate. It gives up after timeout,
+// because the shared session may be blocked by a login which waits for a hanging server.
+func (ps *Server) checkAuth(name string, timeout time.Duration) error {
+	result := make(chan error, 1)
+	go func() {
+		result <- ps.withClient(ps.Logger().With("instance", name), name, func(c *model.Client4, bot *model.User) error {
+			_, apiResponse := c.GetMe("")
+			return checkResponse(apiResponse, http.StatusOK, "Failed to authenticate")
+		})
+	}()
+	select {
+	case err := <-result:
+		return err
+	case <-time.After(timeout):
+		return fmt.Errorf("Error: Authentication didn't finish within %v", timeout)
+	}
+}
+
+func (ps *Server) writeHealth(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		ps.Logger().Warn("Failed to write health status", "err", err)
+	}
+}
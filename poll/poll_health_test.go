@@ -0,0 +1,161 @@
+package poll_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kaakaa/matterpoll-emoji/poll"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type readiness struct {
+	Ready     bool `json:"ready"`
+	Instances []struct {
+		Name string `json:"name"`
+		Host string `json:"host"`
+		Ping struct {
+			OK    bool   `json:"ok"`
+			Error string `json:"error"`
+		} `json:"ping"`
+		Auth struct {
+			OK    bool   `json:"ok"`
+			Error string `json:"error"`
+		} `json:"auth"`
+	} `json:"instances"`
+}
+
+func TestHealthz(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	c, err := getConfig("sample_conf.json")
+	require.Nil(err)
+	ps, err := poll.NewServer(c)
+	require.Nil(err)
+
+	recorder := httptest.NewRecorder()
+	ps.Healthz(recorder, httptest.NewRequest(http.MethodGet, poll.HealthzPath, nil))
+	assert.Equal(http.StatusOK, recorder.Code)
+	assert.Equal("application/json", recorder.Header().Get("Content-Type"))
+	assert.JSONEq(`{"status": "ok"}`, recorder.Body.String())
+}
+
+func TestReadyz(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ps, mm := newTestServer(require)
+	defer mm.Close()
+
+	status, result := getReadiness(require, ps)
+	assert.Equal(http.StatusOK, status)
+	assert.True(result.Ready)
+	require.Len(result.Instances, 1)
+	assert.Equal(mm.URL, result.Instances[0].Host)
+	assert.True(result.Instances[0].Ping.OK)
+	assert.True(result.Instances[0].Auth.OK)
+	assert.Equal(1, mm.Logins())
+
+	// The result is cached for a few seconds
+	mm.Close()
+	status, result = getReadiness(require, ps)
+	assert.Equal(http.StatusOK, status)
+	assert.True(result.Ready)
+}
+
+func TestReadyzFailures(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	mm := newFakeMattermost()
+	defer mm.Close()
+	down := newFakeMattermost()
+	down.Close()
+	c, err := getConfig("sample_conf.json")
+	require.Nil(err)
+	c.Instances = []poll.Instance{
+		{Name: "up", Host: mm.URL, Token: c.Token, User: c.User},
+		{Name: "denied", Host: mm.URL, AccessToken: "invalid"},
+		{Name: "down", Host: down.URL, User: c.User},
+	}
+	c.Instance = poll.Instance{}
+	ps, err := poll.NewServer(c)
+	require.Nil(err)
+
+	status, result := getReadiness(require, ps)
+	assert.Equal(http.StatusServiceUnavailable, status)
+	assert.False(result.Ready)
+	require.Len(result.Instances, 3)
+
+	assert.Equal("up", result.Instances[0].Name)
+	assert.True(result.Instances[0].Ping.OK)
+	assert.True(result.Instances[0].Auth.OK)
+
+	assert.Equal("denied", result.Instances[1].Name)
+	assert.True(result.Instances[1].Ping.OK)
+	assert.False(result.Instances[1].Auth.OK)
+	assert.Contains(result.Instances[1].Auth.Error, "401")
+	assert.NotContains(result.Instances[1].Auth.Error, "invalid")
+
+	assert.Equal("down", result.Instances[2].Name)
+	assert.False(result.Instances[2].Ping.OK)
+	assert.NotEmpty(result.Instances[2].Ping.Error)
+	assert.False(result.Instances[2].Auth.OK)
+}
+
+func TestReadyzSlowInstance(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	mm := newFakeMattermost()
+	defer mm.Close()
+	slow := newFakeMattermost()
+	defer slow.Close()
+	slow.SetAuthDelay(2 * time.Second)
+	c, err := getConfig("sample_conf.json")
+	require.Nil(err)
+	c.Instances = []poll.Instance{
+		{Name: "slow", Host: slow.URL, User: c.User},
+		{Name: "up", Host: mm.URL, Token: c.Token, User: c.User},
+	}
+	c.Instance = poll.Instance{}
+	c.APITimeout = poll.Duration(200 * time.Millisecond)
+	ps, err := poll.NewServer(c)
+	require.Nil(err)
+
+	// Concurrent probes share one check and none of them waits for the hanging login
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			status, result := getReadiness(require, ps)
+			assert.Equal(http.StatusServiceUnavailable, status)
+			require.Len(result.Instances, 2)
+			assert.Equal("slow", result.Instances[0].Name)
+			assert.True(result.Instances[0].Ping.OK)
+			assert.False(result.Instances[0].Auth.OK)
+			assert.NotEmpty(result.Instances[0].Auth.Error)
+			assert.Equal("up", result.Instances[1].Name)
+			assert.True(result.Instances[1].Auth.OK)
+		}()
+	}
+	wg.Wait()
+	assert.True(time.Since(start) < time.Second)
+	assert.Equal(1, mm.Logins())
+}
+
+func getReadiness(require *require.Assertions, ps *poll.Server) (int, *readiness) {
+	recorder := httptest.NewRecorder()
+	ps.Readyz(recorder, httptest.NewRequest(http.MethodGet, poll.ReadyzPath, nil))
+	require.Equal("application/json", recorder.Header().Get("Content-Type"))
+	result := &readiness{}
+	require.Nil(json.NewDecoder(recorder.Body).Decode(result))
+	return recorder.Code, result
+}
@@ -0,0 +1,83 @@
+package poll
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// jobTracker keeps track of the background work of a Server, so that it can be finished before the process exits
+type jobTracker struct {
+	mu      sync.Mutex
+	nextID  int
+	running map[int]string
+	// changed is closed and replaced whenever a job finishes
+	changed chan struct{}
+}
+
+// start registers a job described by name. The returned function must be called when the job is finished.
+func (t *jobTracker) start(name string) func() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.running == nil {
+		t.running = make(map[int]string)
+		t.changed = make(chan struct{})
+	}
+	id := t.nextID
+	t.nextID++
+	t.running[id] = name
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			delete(t.running, id)
+			close(t.changed)
+			t.changed = make(chan struct{})
+		})
+	}
+}
+
+// wait blocks until all jobs are finished or ctx is done. It returns the names of the jobs which didn't finish.
+func (t *jobTracker) wait(ctx context.Context) []string {
+	for {
+		t.mu.Lock()
+		if len(t.running) == 0 {
+			t.mu.Unlock()
+			return nil
+		}
+		changed := t.changed
+		t.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return t.list()
+		}
+	}
+}
+
+// list returns the names of all running jobs in the order they were started
+func (t *jobTracker) list() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	ids := make([]int, 0, len(t.running))
+	for id := range t.running {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	names := make([]string, len(ids))
+	for i, id := range ids {
+		names[i] = t.running[id]
+	}
+	return names
+}
+
+// goJob runs fn in a new goroutine and tracks it as a job described by name
+func (ps *Server) goJob(name string, fn func()) {
+	done := ps.jobs.start(name)
+	go func() {
+		defer done()
+		fn()
+	}()
+}
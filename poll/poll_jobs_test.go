@@ -0,0 +1,80 @@
+package poll_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/kaakaa/matterpoll-emoji/poll"
+	"github.com/mattermost/mattermost-server/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShutdownWaitsForReactions(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ps, mm := newTestServer(require)
+	defer mm.Close()
+	ps.Start()
+
+	mm.SetReactionDelay(50 * time.Millisecond)
+	p := sendTestPoll(require, ps, ":pizza: :sushi: :tada:")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.Nil(ps.Shutdown(ctx))
+	assert.Equal([]string{"pizza", "sushi", "tada"}, mm.Reactions(p.PostID))
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ps, mm := newTestServer(require)
+	defer mm.Close()
+
+	var logs logBuffer
+	ps.SetLogOutput(&logs)
+
+	mm.SetReactionDelay(200 * time.Millisecond)
+	p := sendTestPoll(require, ps, ":pizza: :sushi:")
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.Equal(context.DeadlineExceeded, ps.Shutdown(ctx))
+	assert.Contains(logs.String(), `level=error msg="Shutdown before work finished" job="adding reactions to poll `+p.ID+`"`)
+
+	// Let the reactions finish before the fake server is closed
+	assert.Nil(ps.Shutdown(context.Background()))
+}
+
+func TestShutdownFlushesRefreshes(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ps, mm := newTestServer(require)
+	defer mm.Close()
+	c := ps.Conf()
+	c.RefreshDelay = poll.Duration(time.Hour)
+	ps.Start()
+	require.True(waitFor(func() bool { return mm.Connected() == 1 }))
+
+	p := createTestPoll(require, mm, ps, model.NewId(), ":pizza: :sushi:")
+	mm.AddReaction(p.PostID, model.NewId(), "pizza")
+	// The vote is only shown after the refresh delay
+	assert.False(waitFor(func() bool { return mm.Patches() != 0 }))
+
+	assert.Nil(ps.Shutdown(context.Background()))
+	assert.Contains(mm.Post(p.PostID).Message, ":pizza: `██████████` 1 (100%)")
+}
+
+// sendTestPoll creates a poll like createTestPoll, but doesn't wait for the reactions
+func sendTestPoll(require *require.Assertions, ps *poll.Server, emojis string) *poll.Poll {
+	payload := fmt.Sprintf("token=%s&user_id=%s&channel_id=%s&text=\"%s\" %s", ps.Conf().Token, model.NewId(), model.NewId(), "Lunch?", emojis)
+	sendHttpRequest(require, ps, payload)
+	polls, err := ps.Store.List()
+	require.Nil(err)
+	require.Len(polls, 1)
+	return polls[0]
+}
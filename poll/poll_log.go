@@ -0,0 +1,189 @@
+package poll
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Level is the severity of a log message. Messages below the configured level are dropped.
+type Level int
+
+// The log levels
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var levelNames = []string{"debug", "info", "warn", "error"}
+
+func (l Level) String() string {
+	if l < LevelDebug || l > LevelError {
+		return strconv.Itoa(int(l))
+	}
+	return levelNames[l]
+}
+
+// ParseLevel parses the name of a level like "info". An empty name is LevelInfo.
+func ParseLevel(name string) (Level, error) {
+	if len(name) == 0 {
+		return LevelInfo, nil
+	}
+	for i, n := range levelNames {
+		if n == strings.ToLower(name) {
+			return Level(i), nil
+		}
+	}
+	return LevelInfo, fmt.Errorf("unknown log level %q", name)
+}
+
+// The formats of log messages
+const (
+	LogFormatLogfmt = "logfmt"
+	LogFormatJSON   = "json"
+)
+
+// redacted replaces the values of secret keys in log messages
+const redacted = "[redacted]"
+
+// secretLogKeys are the keys whose values are never logged. They are the secret settings and the secret of anonymous polls.
+var secretLogKeys = func() map[string]bool {
+	keys := map[string]bool{"secret": true}
+	for _, s := range settings {
+		if s.secret {
+			keys[s.key[strings.LastIndex(s.key, ".")+1:]] = true
+		}
+	}
+	return keys
+}()
+
+// Logger writes leveled log messages with key value pairs as logfmt or JSON lines
+type Logger struct {
+	out    *logOutput
+	level  Level
+	json   bool
+	fields []interface{}
+}
+
+// logOutput serializes the writes of a Logger and the loggers derived from it
+type logOutput struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewLogger creates a Logger which writes messages of at least level to w in format
+func NewLogger(w io.Writer, format string, level Level) *Logger {
+	return &Logger{out: &logOutput{w: w}, level: level, json: format == LogFormatJSON}
+}
+
+// With returns a Logger which adds the key value pairs keyvals to every message
+func (l *Logger) With(keyvals ...interface{}) *Logger {
+	fields := make([]interface{}, 0, len(l.fields)+len(keyvals))
+	fields = append(append(fields, l.fields...), keyvals...)
+	return &Logger{out: l.out, level: l.level, json: l.json, fields: fields}
+}
+
+// Debug logs msg with the key value pairs keyvals at LevelDebug
+func (l *Logger) Debug(msg string, keyvals ...interface{}) { l.log(LevelDebug, msg, keyvals) }
+
+// Info logs msg with the key value pairs keyvals at LevelInfo
+func (l *Logger) Info(msg string, keyvals ...interface{}) { l.log(LevelInfo, msg, keyvals) }
+
+// Warn logs msg with the key value pairs keyvals at LevelWarn
+func (l *Logger) Warn(msg string, keyvals ...interface{}) { l.log(LevelWarn, msg, keyvals) }
+
+// Error logs msg with the key value pairs keyvals at LevelError
+func (l *Logger) Error(msg string, keyvals ...interface{}) { l.log(LevelError, msg, keyvals) }
+
+func (l *Logger) log(level Level, msg string, keyvals []interface{}) {
+	if level < l.level {
+		return
+	}
+	pairs := []interface{}{"time", time.Now().UTC().Format(time.RFC3339Nano), "level", level.String(), "msg", msg}
+	pairs = append(append(pairs, l.fields...), keyvals...)
+	if len(pairs)%2 != 0 {
+		pairs = append(pairs, nil)
+	}
+
+	var b bytes.Buffer
+	if l.json {
+		b.WriteString("{")
+	}
+	for i := 0; i < len(pairs); i += 2 {
+		key := fmt.Sprint(pairs[i])
+		value := logValue(pairs[i+1])
+		if secretLogKeys[key] {
+			value = redacted
+		}
+		if l.json {
+			if i != 0 {
+				b.WriteString(",")
+			}
+			k, _ := json.Marshal(key)
+			v, _ := json.Marshal(value)
+			fmt.Fprintf(&b, "%s:%s", k, v)
+			continue
+		}
+		if i != 0 {
+			b.WriteString(" ")
+		}
+		fmt.Fprintf(&b, "%s=%s", key, logfmtValue(value))
+	}
+	if l.json {
+		b.WriteString("}")
+	}
+	b.WriteString("\n")
+
+	l.out.mu.Lock()
+	defer l.out.mu.Unlock()
+	l.out.w.Write(b.Bytes())
+}
+
+// logValue converts v to the string which is logged
+func logValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case error:
+		return v.Error()
+	case fmt.Stringer:
+		return v.String()
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// logfmtValue quotes s, if it contains spaces, quotes or an equals sign
+func logfmtValue(s string) string {
+	if len(s) == 0 || strings.ContainsAny(s, " \t\n\"=") {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// Logger returns the logger of the server. Its level and format follow the configuration.
+func (ps *Server) Logger() *Logger {
+	return ps.logger.Load().(*Logger)
+}
+
+// SetLogOutput makes the server write its log messages to w instead of os.Stderr
+func (ps *Server) SetLogOutput(w io.Writer) {
+	ps.logOutput.mu.Lock()
+	defer ps.logOutput.mu.Unlock()
+	ps.logOutput.w = w
+}
+
+// updateLogger applies the log settings of c
+func (ps *Server) updateLogger(c *Conf) {
+	level, _ := ParseLevel(c.Log.Level)
+	ps.logger.Store(&Logger{out: ps.logOutput, level: level, json: c.Log.Format == LogFormatJSON})
+}
@@ -0,0 +1,123 @@
+package poll_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/kaakaa/matterpoll-emoji/poll"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoggerLogfmt(t *testing.T) {
+	assert := assert.New(t)
+
+	var b bytes.Buffer
+	lg := poll.NewLogger(&b, poll.LogFormatLogfmt, poll.LevelInfo).With("request_id", "abc")
+	lg.Debug("Hidden")
+	lg.Info("Poll posted", "poll_id", "p1", "question", "Lunch today?")
+	lg.Error("Failed", "err", http.ErrServerClosed)
+
+	lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+	require.Len(t, lines, 2)
+	assert.Regexp(`^time=\S+ level=info msg="Poll posted" request_id=abc poll_id=p1 question="Lunch today\?"$`, lines[0])
+	assert.Regexp(`^time=\S+ level=error msg=Failed request_id=abc err="http: Server closed"$`, lines[1])
+}
+
+func TestLoggerJSON(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	var b bytes.Buffer
+	lg := poll.NewLogger(&b, poll.LogFormatJSON, poll.LevelDebug)
+	lg.With("request_id", "abc").Debug("Vote counted", "option", `say "hi"`)
+
+	var entry map[string]string
+	require.Nil(json.Unmarshal(b.Bytes(), &entry))
+	assert.Equal("debug", entry["level"])
+	assert.Equal("Vote counted", entry["msg"])
+	assert.Equal("abc", entry["request_id"])
+	assert.Equal(`say "hi"`, entry["option"])
+	assert.NotEmpty(entry["time"])
+}
+
+func TestLoggerRedactsSecrets(t *testing.T) {
+	assert := assert.New(t)
+
+	var b bytes.Buffer
+	lg := poll.NewLogger(&b, poll.LogFormatLogfmt, poll.LevelInfo).With("token", "tok3n")
+	lg.Warn("Login", "password", "passw0rd", "access_token", "acc3ss", "secret", "s3cret", "user", "bot")
+
+	assert.Contains(b.String(), "token=[redacted] password=[redacted] access_token=[redacted] secret=[redacted] user=bot")
+	for _, s := range []string{"tok3n", "passw0rd", "acc3ss", "s3cret"} {
+		assert.NotContains(b.String(), s)
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	assert := assert.New(t)
+	for name, expected := range map[string]poll.Level{
+		"":      poll.LevelInfo,
+		"debug": poll.LevelDebug,
+		"INFO":  poll.LevelInfo,
+		"warn":  poll.LevelWarn,
+		"error": poll.LevelError,
+	} {
+		l, err := poll.ParseLevel(name)
+		assert.Nil(err, name)
+		assert.Equal(expected, l, name)
+	}
+	_, err := poll.ParseLevel("verbose")
+	assert.NotNil(err)
+}
+
+func TestLogRequestID(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	mm := newFakeMattermost()
+	defer mm.Close()
+	c, err := getConfig("sample_conf.json")
+	require.Nil(err)
+	c.Host = mm.URL
+	c.Log.Level = "debug"
+	ps, err := poll.NewServer(c)
+	require.Nil(err)
+	var logs logBuffer
+	ps.SetLogOutput(&logs)
+
+	mm.FailReaction("sushi", http.StatusBadRequest)
+	sendTestPoll(require, ps, ":pizza: :sushi:")
+	require.True(waitFor(func() bool { return strings.Contains(logs.String(), "Failed to add option") }))
+
+	m := regexp.MustCompile(`msg="Slash command answered" request_id=(\w+)`).FindStringSubmatch(logs.String())
+	require.Len(m, 2)
+	for _, msg := range []string{`"Bot user logged in"`, `"Poll posted"`, `"Failed to add option"`} {
+		assert.Regexp(`msg=`+msg+` request_id=`+m[1], logs.String())
+	}
+	assert.NotContains(logs.String(), c.User.Password)
+	assert.NotContains(logs.String(), c.Token)
+}
+
+// logBuffer collects log messages. It may be written to while the test reads it.
+type logBuffer struct {
+	mu sync.Mutex
+	b  bytes.Buffer
+}
+
+func (l *logBuffer) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.b.Write(p)
+}
+
+func (l *logBuffer) String() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.b.String()
+}
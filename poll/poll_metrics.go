@@ -0,0 +1,174 @@
+package poll
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// MetricsPath is the path the metrics are served at in the Prometheus text format
+const MetricsPath = "/metrics"
+
+// Outcomes of slash commands counted by matterpoll_commands_total
+const (
+	outcomeCreated        = "created"
+	outcomeEnded          = "ended"
+	outcomeOK             = "ok"
+	outcomeWrongFormat    = "wrong_format"
+	outcomeTokenMismatch  = "token_mismatch"
+	outcomeBadMediaType   = "bad_media_type"
+	outcomeUnknownEmoji   = "unknown_emoji"
+	outcomeUnknownCommand = "unknown_command"
+	outcomeNotFound       = "not_found"
+	outcomeRejected       = "rejected"
+	outcomeFailed         = "failed"
+)
+
+// reactionLatencyBuckets are the upper bounds in seconds of the buckets of matterpoll_poll_reactions_seconds
+var reactionLatencyBuckets = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// metrics are the counters and histograms of a Server
+type metrics struct {
+	mu sync.Mutex
+	// commands counts slash commands by command and outcome
+	commands map[[2]string]int
+	// reactionAttempts counts the reactions the bot user tried to save, including retries
+	reactionAttempts int
+	// reactionFailures counts the failed reactions by status code
+	reactionFailures map[int]int
+	// logins counts the login attempts of the bot users by result
+	logins map[string]int
+	// reactionLatency is a histogram of the time from a slash command until the last reaction of the poll was added
+	reactionLatency histogram
+}
+
+// histogram counts observations in cumulative buckets like a Prometheus histogram
+type histogram struct {
+	bounds []float64
+	counts []int
+	sum    float64
+	count  int
+}
+
+func newMetrics() *metrics {
+	return &metrics{
+		commands:         make(map[[2]string]int),
+		reactionFailures: make(map[int]int),
+		logins:           make(map[string]int),
+		reactionLatency:  histogram{bounds: reactionLatencyBuckets, counts: make([]int, len(reactionLatencyBuckets))},
+	}
+}
+
+func (m *metrics) countCommand(command, outcome string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.commands[[2]string{command, outcome}]++
+}
+
+// countReaction counts an attempt to save a reaction, which failed with err, if it isn't nil
+func (m *metrics) countReaction(err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.reactionAttempts++
+	if err == nil {
+		return
+	}
+	status := 0
+	if e, ok := err.(*APIError); ok {
+		status = e.StatusCode
+	}
+	m.reactionFailures[status]++
+}
+
+func (m *metrics) countLogin(err error) {
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.logins[result]++
+}
+
+func (m *metrics) observeReactionLatency(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.reactionLatency.observe(d.Seconds())
+}
+
+func (h *histogram) observe(v float64) {
+	for i, bound := range h.bounds {
+		if v <= bound {
+			h.counts[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+// writeTo writes all metrics to w in the Prometheus text format
+func (m *metrics) writeTo(w io.Writer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var b bytes.Buffer
+
+	b.WriteString("# HELP matterpoll_commands_total Slash commands by command and outcome.\n")
+	b.WriteString("# TYPE matterpoll_commands_total counter\n")
+	var keys [][2]string
+	for k := range m.commands {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i][0] < keys[j][0] || (keys[i][0] == keys[j][0] && keys[i][1] < keys[j][1])
+	})
+	for _, k := range keys {
+		fmt.Fprintf(&b, "matterpoll_commands_total{command=%q,outcome=%q} %d\n", k[0], k[1], m.commands[k])
+	}
+
+	b.WriteString("# HELP matterpoll_reaction_attempts_total Reactions the bot users tried to add, including retries.\n")
+	b.WriteString("# TYPE matterpoll_reaction_attempts_total counter\n")
+	fmt.Fprintf(&b, "matterpoll_reaction_attempts_total %d\n", m.reactionAttempts)
+
+	b.WriteString("# HELP matterpoll_reaction_failures_total Reactions which couldn't be added by status code. The status code 0 is a connection error.\n")
+	b.WriteString("# TYPE matterpoll_reaction_failures_total counter\n")
+	var statuses []int
+	for status := range m.reactionFailures {
+		statuses = append(statuses, status)
+	}
+	sort.Ints(statuses)
+	for _, status := range statuses {
+		fmt.Fprintf(&b, "matterpoll_reaction_failures_total{status=\"%d\"} %d\n", status, m.reactionFailures[status])
+	}
+
+	b.WriteString("# HELP matterpoll_login_attempts_total Login attempts of the bot users by result.\n")
+	b.WriteString("# TYPE matterpoll_login_attempts_total counter\n")
+	for _, result := range []string{"failure", "success"} {
+		fmt.Fprintf(&b, "matterpoll_login_attempts_total{result=%q} %d\n", result, m.logins[result])
+	}
+
+	h := &m.reactionLatency
+	b.WriteString("# HELP matterpoll_poll_reactions_seconds Time from a slash command until the last reaction of the poll was added.\n")
+	b.WriteString("# TYPE matterpoll_poll_reactions_seconds histogram\n")
+	for i, bound := range h.bounds {
+		fmt.Fprintf(&b, "matterpoll_poll_reactions_seconds_bucket{le=%q} %d\n", strconv.FormatFloat(bound, 'g', -1, 64), h.counts[i])
+	}
+	fmt.Fprintf(&b, "matterpoll_poll_reactions_seconds_bucket{le=\"+Inf\"} %d\n", h.count)
+	fmt.Fprintf(&b, "matterpoll_poll_reactions_seconds_sum %s\n", strconv.FormatFloat(h.sum, 'g', -1, 64))
+	fmt.Fprintf(&b, "matterpoll_poll_reactions_seconds_count %d\n", h.count)
+
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// Metrics serves the metrics of the server in the Prometheus text format
+func (ps *Server) Metrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	if err := ps.metrics.writeTo(w); err != nil {
+		ps.Logger().Warn("Failed to write metrics", "err", err)
+	}
+}
@@ -0,0 +1,73 @@
+package poll_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kaakaa/matterpoll-emoji/poll"
+	"github.com/mattermost/mattermost-server/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ps, mm := newTestServer(require)
+	defer mm.Close()
+	c := ps.Conf()
+
+	mm.FailReaction("sushi", http.StatusBadRequest)
+	sendTestPoll(require, ps, ":pizza: :sushi: :taco:")
+	require.True(waitFor(func() bool {
+		return strings.Contains(getMetrics(require, ps), "matterpoll_poll_reactions_seconds_count 1")
+	}))
+
+	payload := fmt.Sprintf("token=%s&user_id=%s&channel_id=%s&text=\"Lunch?\" :pizza:", model.NewId(), model.NewId(), model.NewId())
+	sendHttpRequest(require, ps, payload)
+	// A token with a wrong length is a mismatch as well, although the answer is the same as for a malformed channel id
+	payload = fmt.Sprintf("token=%s&user_id=%s&channel_id=%s&text=\"Lunch?\" :pizza:", "abc", model.NewId(), model.NewId())
+	sendHttpRequest(require, ps, payload)
+	payload = fmt.Sprintf("token=%s&user_id=%s&channel_id=%s&text=\"Lunch?\"", c.Token, model.NewId(), model.NewId())
+	sendHttpRequest(require, ps, payload)
+	payload = fmt.Sprintf("token=%s&user_id=%s&text=help", c.Token, model.NewId())
+	sendHttpRequest(require, ps, payload)
+	r, err := http.NewRequest(http.MethodPost, "localhost:8505/poll", strings.NewReader(payload))
+	require.Nil(err)
+	ps.Cmd(httptest.NewRecorder(), r)
+
+	metrics := getMetrics(require, ps)
+	for _, line := range []string{
+		`matterpoll_commands_total{command="create",outcome="created"} 1`,
+		`matterpoll_commands_total{command="create",outcome="token_mismatch"} 2`,
+		`matterpoll_commands_total{command="create",outcome="wrong_format"} 1`,
+		`matterpoll_commands_total{command="help",outcome="ok"} 1`,
+		`matterpoll_commands_total{command="unknown",outcome="bad_media_type"} 1`,
+		`matterpoll_reaction_attempts_total 3`,
+		`matterpoll_reaction_failures_total{status="400"} 1`,
+		`matterpoll_login_attempts_total{result="failure"} 0`,
+		`matterpoll_login_attempts_total{result="success"} 1`,
+		`matterpoll_poll_reactions_seconds_bucket{le="+Inf"} 1`,
+		`matterpoll_poll_reactions_seconds_count 1`,
+		`# TYPE matterpoll_poll_reactions_seconds histogram`,
+	} {
+		assert.Contains(metrics, line+"\n")
+	}
+}
+
+func getMetrics(require *require.Assertions, ps *poll.Server) string {
+	r, err := http.NewRequest(http.MethodGet, "localhost:8505"+poll.MetricsPath, nil)
+	require.Nil(err)
+	recorder := httptest.NewRecorder()
+	ps.Metrics(recorder, r)
+	require.Equal(http.StatusOK, recorder.Code)
+	require.Contains(recorder.Header().Get("Content-Type"), "text/plain")
+	body, err := ioutil.ReadAll(recorder.Result().Body)
+	require.Nil(err)
+	return string(body)
+}
@@ -0,0 +1,105 @@
+package poll
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/mattermost/mattermost-server/model"
+)
+
+// ResponseTextReactionsFailed is the direct message which is sent to the creator of a poll, if some options couldn't be added as reactions.
+// It is formatted with the question of the poll and the list of failed options.
+const ResponseTextReactionsFailed = "Matterpoll couldn't add all options to your poll **%s**. Voters can still add these reactions themselves:\n%s"
+
+// reactionBackoff are the delays before a reaction is saved again after a transient failure
+var reactionBackoff = []time.Duration{250 * time.Millisecond, 500 * time.Millisecond, time.Second}
+
+// reactionFailure is an option which couldn't be added as a reaction
+type reactionFailure struct {
+	emoji string
+	err   error
+}
+
+// addReaction adds the options of p as reactions of the bot user to the poll post. Options which can't be added are reported to the creator of the poll.
+// start is the time the poll was requested. The time until all reactions were added is recorded in the metrics.
+func (ps *Server) addReaction(lg *Logger, p *Poll, start time.Time) {
+	defer func() { ps.metrics.observeReactionLatency(time.Since(start)) }()
+	err := ps.withClient(lg, p.Instance, func(c *model.Client4, bot *model.User) error {
+		failures, err := ps.reaction(c, bot.Id, p.PostID, p.Options)
+		if err != nil {
+			return err
+		}
+		if len(failures) == 0 {
+			return nil
+		}
+		for _, f := range failures {
+			lg.Warn("Failed to add option", "emoji", f.emoji, "err", f.err)
+		}
+		return reportReactionFailures(c, bot.Id, p, failures)
+	})
+	if err != nil {
+		lg.Error("Failed to add options", "err", err)
+		return
+	}
+	lg.Debug("Options added", "seconds", time.Since(start).Seconds())
+}
+
+// reaction saves a reaction with every emoji on the post with postID. An emoji which fails permanently doesn't stop the remaining emojis.
+// The failed emojis are returned. An error is only returned, if the session has expired.
+func (ps *Server) reaction(c *model.Client4, userID string, postID string, emojis []string) ([]reactionFailure, error) {
+	var failures []reactionFailure
+	for _, e := range emojis {
+		err := ps.saveReaction(c, &model.Reaction{
+			UserId:    userID,
+			PostId:    postID,
+			EmojiName: e,
+		})
+		if apiErr, ok := err.(*APIError); ok && apiErr.StatusCode == http.StatusUnauthorized {
+			return nil, err
+		}
+		if err != nil {
+			failures = append(failures, reactionFailure{emoji: e, err: err})
+		}
+	}
+	return failures, nil
+}
+
+// saveReaction saves r and tries again with increasing delays, if the server is unavailable or rate limits the bot user
+func (ps *Server) saveReaction(c *model.Client4, r *model.Reaction) error {
+	for try := 0; ; try++ {
+		_, apiResponse := c.SaveReaction(r)
+		err := checkResponse(apiResponse, http.StatusOK, "Failed to save reaction")
+		ps.metrics.countReaction(err)
+		if err == nil || try == len(reactionBackoff) || !isTransient(apiResponse.StatusCode) {
+			return err
+		}
+		time.Sleep(reactionBackoff[try])
+	}
+}
+
+// isTransient reports whether a request which failed with status may succeed later
+func isTransient(status int) bool {
+	return status == 0 || status == http.StatusTooManyRequests || status >= http.StatusInternalServerError
+}
+
+// reportReactionFailures sends a direct message with the failed options to the creator of p
+func reportReactionFailures(c *model.Client4, botID string, p *Poll, failures []reactionFailure) error {
+	if len(p.Creator) == 0 {
+		return nil
+	}
+	channel, apiResponse := c.CreateDirectChannel(botID, p.Creator)
+	if err := checkResponse(apiResponse, http.StatusCreated, "Failed to create direct channel"); err != nil {
+		return err
+	}
+	var b bytes.Buffer
+	for _, f := range failures {
+		fmt.Fprintf(&b, "* :%s: `%s`\n", f.emoji, f.emoji)
+	}
+	_, apiResponse = c.CreatePost(&model.Post{
+		ChannelId: channel.Id,
+		Message:   fmt.Sprintf(ResponseTextReactionsFailed, p.Question, b.String()),
+	})
+	return checkResponse(apiResponse, http.StatusCreated, "Failed to report failed reactions")
+}
@@ -0,0 +1,61 @@
+package poll_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/kaakaa/matterpoll-emoji/poll"
+	"github.com/mattermost/mattermost-server/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReactionFailures(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ps, mm := newTestServer(require)
+	defer mm.Close()
+	c := ps.Conf()
+
+	// sushi fails permanently, taco only twice and fries once with a rate limit
+	mm.FailReaction("sushi", http.StatusBadRequest)
+	mm.FailReaction("taco", http.StatusServiceUnavailable, http.StatusBadGateway, 0)
+	mm.FailReaction("fries", http.StatusTooManyRequests, 0)
+
+	creator := model.NewId()
+	payload := fmt.Sprintf("token=%s&user_id=%s&channel_id=%s&text=\"Lunch?\" :pizza: :sushi: :taco: :fries:", c.Token, creator, model.NewId())
+	sendHttpRequest(require, ps, payload)
+
+	// The retries take about as long as waitFor, so wait until the reactions are done instead
+	require.Nil(ps.Shutdown(context.Background()))
+	posts := mm.Posts()
+	require.Len(posts, 2)
+	assert.Equal([]string{"pizza", "taco", "fries"}, mm.Reactions(posts[0].Id))
+
+	report := posts[1]
+	assert.ElementsMatch([]string{mm.BotID, creator}, mm.DirectChannel(report.ChannelId))
+	assert.Equal(fmt.Sprintf(poll.ResponseTextReactionsFailed, "Lunch?", "* :sushi: `sushi`\n"), report.Message)
+}
+
+func TestReactionFailuresExhaustRetries(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ps, mm := newTestServer(require)
+	defer mm.Close()
+	c := ps.Conf()
+
+	mm.FailReaction("pizza", http.StatusInternalServerError)
+
+	payload := fmt.Sprintf("token=%s&user_id=%s&channel_id=%s&text=\"Lunch?\" :pizza: :sushi:", c.Token, model.NewId(), model.NewId())
+	sendHttpRequest(require, ps, payload)
+	require.Nil(ps.Shutdown(context.Background()))
+
+	posts := mm.Posts()
+	require.Len(posts, 2)
+	assert.Equal([]string{"sushi"}, mm.Reactions(posts[0].Id))
+	assert.Contains(posts[1].Message, ":pizza:")
+}
@@ -0,0 +1,88 @@
+package poll
+
+import (
+	"reflect"
+)
+
+// restartKeys are the config values which are only applied when Matterpoll is restarted
+var restartKeys = map[string]bool{
+	"listen":           true,
+	"store.type":       true,
+	"store.path":       true,
+	"refresh_interval": true,
+}
+
+// connectionKeys are the config values which require the bot user to log in again
+var connectionKeys = map[string]bool{
+	"host":          true,
+	"user.id":       true,
+	"user.password": true,
+	"access_token":  true,
+	"instances":     true,
+	// The sessions are created with the timeout
+	"api_timeout": true,
+}
+
+// Conf returns the current configuration of the server
+func (ps *Server) Conf() *Conf {
+	return ps.conf.Load().(*Conf)
+}
+
+// SetConf replaces the configuration of the server with c. Requests which are already being handled are not interrupted.
+// c must have been validated, e.g. by ConfLoader.Load. The changes are logged without the values of secrets.
+func (ps *Server) SetConf(c *Conf) {
+	old := ps.Conf()
+	ps.conf.Store(c)
+	ps.updateLogger(c)
+
+	lg := ps.Logger()
+	reconnect := false
+	for _, change := range confChanges(old, c) {
+		lg.Info("Config changed", change.keyvals()...)
+		if restartKeys[change.key] {
+			lg.Warn("Config is applied after a restart", "key", change.key)
+		}
+		reconnect = reconnect || connectionKeys[change.key]
+	}
+	if reconnect {
+		ps.dropSessions()
+		ps.clearEmojis()
+		select {
+		case ps.reconnect <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// confChange is a config value which differs between two configs
+type confChange struct {
+	key      string
+	secret   bool
+	old, new string
+}
+
+// keyvals returns the key value pairs which are logged for the change. The values of secrets are left out.
+func (c confChange) keyvals() []interface{} {
+	if c.secret {
+		return []interface{}{"key", c.key}
+	}
+	return []interface{}{"key", c.key, "old", c.old, "new", c.new}
+}
+
+// confChanges returns all values which differ between old and new
+func confChanges(old, new *Conf) []confChange {
+	var changes []confChange
+	for _, s := range settings {
+		o, n := s.get(old), s.get(new)
+		if o != n {
+			changes = append(changes, confChange{key: s.key, secret: s.secret, old: o, new: n})
+		}
+	}
+	if !reflect.DeepEqual(old.Tokens, new.Tokens) {
+		changes = append(changes, confChange{key: "tokens", secret: true})
+	}
+	if !reflect.DeepEqual(old.Instances, new.Instances) {
+		changes = append(changes, confChange{key: "instances", secret: true})
+	}
+	return changes
+}
@@ -0,0 +1,95 @@
+package poll_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/kaakaa/matterpoll-emoji/poll"
+	"github.com/mattermost/mattermost-server/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetConfToken(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ps, mm := newTestServer(require)
+	defer mm.Close()
+	c := ps.Conf()
+
+	oldToken := c.Token
+	newConf := *c
+	newConf.Token = model.NewId()
+	ps.SetConf(&newConf)
+	assert.Equal(&newConf, ps.Conf())
+
+	payload := fmt.Sprintf("token=%s&user_id=%s&channel_id=%s&text=\"%s\"%s", oldToken, model.NewId(), model.NewId(), "Lunch?", ":pizza:")
+	response, _ := sendHttpRequest(require, ps, payload)
+	assert.Equal(poll.ErrorTokenMissmatch, response.Text)
+
+	payload = fmt.Sprintf("token=%s&user_id=%s&channel_id=%s&text=\"%s\"%s", newConf.Token, model.NewId(), model.NewId(), "Lunch?", ":pizza:")
+	response, _ = sendHttpRequest(require, ps, payload)
+	assert.NotEqual(poll.ErrorTokenMissmatch, response.Text)
+	assert.Len(mm.Posts(), 1)
+}
+
+func TestSetConfCredentials(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ps, mm := newTestServer(require)
+	defer mm.Close()
+	c := ps.Conf()
+	ps.Start()
+	defer ps.Stop()
+	require.True(waitFor(func() bool { return mm.Connected() == 1 }))
+	require.Equal(1, mm.Logins())
+
+	var logs logBuffer
+	ps.SetLogOutput(&logs)
+
+	newConf := *c
+	newConf.User = poll.User{}
+	newConf.AccessToken = mm.CreateAccessToken()
+	newConf.Listen = ":9000"
+	ps.SetConf(&newConf)
+
+	// The websocket connection is opened again with the access token
+	assert.True(waitFor(func() bool { return mm.Connected() == 2 }))
+	p := createTestPoll(require, mm, ps, model.NewId(), ":pizza: :sushi:")
+	assert.True(waitFor(func() bool { return len(mm.Reactions(p.PostID)) == 2 }))
+	assert.Equal(1, mm.Logins())
+
+	assert.Contains(logs.String(), `msg="Config changed" key=access_token`+"\n")
+	assert.Contains(logs.String(), `msg="Config changed" key=user.password`+"\n")
+	assert.Contains(logs.String(), `msg="Config changed" key=listen old=:8505 new=:9000`)
+	assert.Contains(logs.String(), `msg="Config is applied after a restart" key=listen`)
+	assert.NotContains(logs.String(), newConf.AccessToken)
+	assert.NotContains(logs.String(), c.User.Password)
+}
+
+func TestSetConfAPITimeout(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ps, mm := newTestServer(require)
+	defer mm.Close()
+	c := ps.Conf()
+	// The session is created with the default timeout
+	createTestPoll(require, mm, ps, model.NewId(), ":pizza:")
+
+	newConf := *c
+	newConf.APITimeout = poll.Duration(50 * time.Millisecond)
+	ps.SetConf(&newConf)
+
+	// With the old timeout adding the reaction would hang for three seconds
+	mm.SetReactionDelay(3 * time.Second)
+	payload := fmt.Sprintf("token=%s&user_id=%s&channel_id=%s&text=\"%s\"%s", c.Token, model.NewId(), model.NewId(), "Lunch?", ":pizza:")
+	sendHttpRequest(require, ps, payload)
+	ctx, cancel := context.WithTimeout(context.Background(), 2500*time.Millisecond)
+	defer cancel()
+	assert.Nil(ps.Shutdown(ctx))
+}
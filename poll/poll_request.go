@@ -1,17 +1,45 @@
 package poll
 
 import (
+	"errors"
 	"fmt"
 	"regexp"
 	"strings"
+	"time"
 )
 
+// emojiNamePattern matches the names Mattermost allows for emojis
+var emojiNamePattern = regexp.MustCompile(`^[a-z0-9_+-]+$`)
+
 // Request wraps up all information needed to answer a poll request
 type Request struct {
+	UserID    string
+	TeamID    string
 	ChannelID string
-	Token     string
-	Message   string
-	Emojis    []string
+	// RootID is the id of the thread the slash command was sent in. It is empty outside of threads.
+	RootID      string
+	UserName    string
+	ChannelName string
+	TeamDomain  string
+	Token       string
+	Message     string
+	Emojis      []string
+	// Labels describe the emojis with the same index. It is nil, if no emoji has a label.
+	Labels []string
+	// Until is the time the poll is ended automatically. It is zero, if the poll has no deadline.
+	Until time.Time
+	// Single is true, if every user may only vote for one option
+	Single bool
+	// Anonymous is true, if the options are shown as buttons and nobody can see who voted for which option
+	Anonymous bool
+}
+
+// EndRequest wraps up all information needed to end a poll
+type EndRequest struct {
+	UserID string
+	TeamID string
+	Token  string
+	PollID string
 }
 
 const (
@@ -20,7 +48,19 @@ const (
 	ErrorTextWrongFormat = `The message format is wrong. Try this instead: ` + backTick + `/poll \"What do you gys wanna grab for lunch?\" :pizza: :sushi:` + backTick
 	// ErrorTokenMissmatch is an error message and is used, if the token comparison fails
 	ErrorTokenMissmatch = `An error occurred. Ask your administrator to check the Matterpoll config settings.`
-	// ErrorWrongLength is an error message and is used, if the channel id or the token have a wrong length
+	// ErrorEndWrongFormat is an error message and is used, if the end command isn`t formated correct
+	ErrorEndWrongFormat = `The message format is wrong. Try this instead: ` + backTick + `/poll end <poll id>` + backTick
+	// ErrorInvalidOption is an error message and is used, if an option after the emojis is unknown or has an invalid value
+	ErrorInvalidOption = `An option is invalid. Valid options are ` + backTick + `--duration=2h` + backTick + `, ` + backTick + `--until=2006-01-02T15:04` + backTick + `, ` + backTick + `--single` + backTick + ` and ` + backTick + `--anonymous` + backTick + `.`
+	// ErrorDeadlinePassed is an error message and is used, if the deadline of a poll is not in the future
+	ErrorDeadlinePassed = `The end of the poll must be in the future.`
+	// ErrorTooManyOptions is an error message and is used, if a poll has more text options than emojis can be assigned automatically. It is formatted with the maximum number of options.
+	ErrorTooManyOptions = `A poll can have at most %d text options. Use emojis for more options.`
+	// ErrorUnknownUnicodeEmoji is an error message and is used, if an option is a Unicode character which isn't a known emoji. It is formatted with the option.
+	ErrorUnknownUnicodeEmoji = `Matterpoll doesn't know the emoji %s. Use its name like ` + backTick + `:pizza:` + backTick + ` instead.`
+	// ErrorInvalidEmojiName is an error message and is used, if the name of an emoji contains characters Mattermost doesn't allow. It is formatted with the option.
+	ErrorInvalidEmojiName = `%s isn't a valid emoji. Emoji names consist of lowercase letters, digits, ` + backTick + `_` + backTick + `, ` + backTick + `-` + backTick + ` and ` + backTick + `+` + backTick + `.`
+	// ErrorWrongLength is an error message and is used, if the ids or the token have a wrong length or the user id is missing
 	ErrorWrongLength = `An error occurred. Try the same command again. If it fails again, contact your administrator.`
 )
 
@@ -29,28 +69,165 @@ func NewRequest(u map[string][]string) (*Request, error) {
 	p := &Request{}
 	for key, values := range u {
 		switch key {
+		case "user_id":
+			if err := checkIDLength(values[0]); err != nil {
+				return nil, err
+			}
+			p.UserID = values[0]
+		case "team_id":
+			if err := checkIDLength(values[0]); err != nil {
+				return nil, err
+			}
+			p.TeamID = values[0]
 		case "channel_id":
 			if err := checkIDLength(values[0]); err != nil {
 				return nil, err
 			}
 			p.ChannelID = values[0]
-		case "token":
+		case "root_id":
+			if len(values[0]) == 0 {
+				continue
+			}
 			if err := checkIDLength(values[0]); err != nil {
 				return nil, err
 			}
+			p.RootID = values[0]
+		case "user_name":
+			p.UserName = values[0]
+		case "channel_name":
+			p.ChannelName = values[0]
+		case "team_domain":
+			p.TeamDomain = values[0]
+		case "token":
+			if err := checkTokenLength(values[0]); err != nil {
+				return nil, err
+			}
 			p.Token = values[0]
 		case "text":
-			message, emojis, err := parseText(values[0])
+			message, emojis, labels, options, err := parseText(values[0])
 			if err != nil {
 				return nil, err
 			}
-			p.Message, p.Emojis = message, emojis
+			p.Message, p.Emojis, p.Labels = message, emojis, labels
+			if len(p.Emojis) == 0 {
+				if err := p.numberOptions(); err != nil {
+					return nil, err
+				}
+			}
+			if err := p.parseOptions(options, time.Now()); err != nil {
+				return nil, err
+			}
 		}
 	}
+	// The user is required to check who may end the poll
+	if len(p.UserID) == 0 {
+		return nil, fmt.Errorf(ErrorWrongLength)
+	}
 	return p, nil
 }
 
-func parseText(text string) (string, []string, error) {
+// CommandRequest wraps up all information needed to answer a subcommand like help, list or results
+type CommandRequest struct {
+	UserID    string
+	TeamID    string
+	ChannelID string
+	Token     string
+	// Command is the name of the subcommand
+	Command string
+	// Args are the words after the subcommand
+	Args []string
+}
+
+// commandName returns the first word of the slash command text in u. It is empty, if the text starts with a quoted question.
+func commandName(u map[string][]string) string {
+	values := u["text"]
+	if len(values) == 0 {
+		return ""
+	}
+	fields := strings.Fields(values[0])
+	if len(fields) == 0 || strings.ContainsAny(fields[0][:1], "`'\"") {
+		return ""
+	}
+	return fields[0]
+}
+
+// NewCommandRequest validates the data in map and wraps it into a CommandRequest struct
+func NewCommandRequest(u map[string][]string) (*CommandRequest, error) {
+	p := &CommandRequest{}
+	for key, values := range u {
+		switch key {
+		case "user_id":
+			if err := checkIDLength(values[0]); err != nil {
+				return nil, err
+			}
+			p.UserID = values[0]
+		case "team_id":
+			if err := checkIDLength(values[0]); err != nil {
+				return nil, err
+			}
+			p.TeamID = values[0]
+		case "channel_id":
+			if err := checkIDLength(values[0]); err != nil {
+				return nil, err
+			}
+			p.ChannelID = values[0]
+		case "token":
+			if err := checkTokenLength(values[0]); err != nil {
+				return nil, err
+			}
+			p.Token = values[0]
+		case "text":
+			fields := strings.Fields(values[0])
+			if len(fields) != 0 {
+				p.Command, p.Args = fields[0], fields[1:]
+			}
+		}
+	}
+	return p, nil
+}
+
+// NewEndRequest validates the data in map and wraps it into an EndRequest struct
+func NewEndRequest(u map[string][]string) (*EndRequest, error) {
+	p := &EndRequest{}
+	for key, values := range u {
+		switch key {
+		case "user_id":
+			if err := checkIDLength(values[0]); err != nil {
+				return nil, err
+			}
+			p.UserID = values[0]
+		case "team_id":
+			if err := checkIDLength(values[0]); err != nil {
+				return nil, err
+			}
+			p.TeamID = values[0]
+		case "token":
+			if err := checkTokenLength(values[0]); err != nil {
+				return nil, err
+			}
+			p.Token = values[0]
+		case "text":
+			fields := strings.Fields(values[0])
+			if len(fields) != 2 || fields[0] != "end" {
+				return nil, fmt.Errorf(ErrorEndWrongFormat)
+			}
+			p.PollID = fields[1]
+		}
+	}
+	// Only the creator of a poll may end it
+	if len(p.UserID) == 0 {
+		return nil, fmt.Errorf(ErrorWrongLength)
+	}
+	return p, nil
+}
+
+// parseText splits text into the poll message, the option emojis, their labels and the options starting with "--".
+// Emojis are given by their names like :pizza: or as Unicode characters, which are converted to their names. Every emoji may be followed by a quoted label. labels is nil, if no emoji has a label.
+// If the poll options are given as quoted text only, emojis is nil and the emojis are assigned by numberOptions.
+func parseText(text string) (string, []string, []string, []string, error) {
+	if len(text) == 0 {
+		return "", nil, nil, nil, fmt.Errorf(ErrorTextWrongFormat)
+	}
 	var re *(regexp.Regexp)
 	switch text[0] {
 	case '`':
@@ -60,23 +237,190 @@ func parseText(text string) (string, []string, error) {
 	case '"':
 		re = regexp.MustCompile("\"([^\"]+)\"(.+)")
 	default:
-		return "", nil, fmt.Errorf(ErrorTextWrongFormat)
+		return "", nil, nil, nil, fmt.Errorf(ErrorTextWrongFormat)
 	}
 	e := re.FindStringSubmatch(text)
 	if len(e) != 3 {
-		return "", nil, fmt.Errorf(ErrorTextWrongFormat)
+		return "", nil, nil, nil, fmt.Errorf(ErrorTextWrongFormat)
+	}
+	words, err := splitWords(e[2])
+	if err != nil {
+		return "", nil, nil, nil, err
+	}
+	var emojis, labels, options []string
+	labeled := false
+	// textOnly is true, if the options are given as quoted text without emojis
+	textOnly := len(words) != 0 && isQuoted(words[0])
+	for _, v := range words {
+		switch {
+		case strings.HasPrefix(v, "--"):
+			options = append(options, v)
+		case len(options) != 0 || (isQuoted(v) && len(v) < 3):
+			return "", nil, nil, nil, fmt.Errorf(ErrorTextWrongFormat)
+		case isQuoted(v) && textOnly:
+			labels = append(labels, v[1:len(v)-1])
+		case isQuoted(v):
+			// A label belongs to the emoji right before it
+			if len(labels[len(labels)-1]) != 0 {
+				return "", nil, nil, nil, fmt.Errorf(ErrorTextWrongFormat)
+			}
+			labels[len(labels)-1] = v[1 : len(v)-1]
+			labeled = true
+		case !textOnly && len(v) >= 3 && strings.HasPrefix(v, ":") && strings.HasSuffix(v, ":"):
+			if !emojiNamePattern.MatchString(v[1 : len(v)-1]) {
+				return "", nil, nil, nil, fmt.Errorf(ErrorInvalidEmojiName, v)
+			}
+			emojis = append(emojis, v[1:len(v)-1])
+			labels = append(labels, "")
+		case !textOnly && containsNonASCII(v):
+			names, ok := emojiNames(v)
+			if !ok {
+				return "", nil, nil, nil, fmt.Errorf(ErrorUnknownUnicodeEmoji, v)
+			}
+			for _, name := range names {
+				emojis = append(emojis, name)
+				labels = append(labels, "")
+			}
+		default:
+			return "", nil, nil, nil, fmt.Errorf(ErrorTextWrongFormat)
+		}
+	}
+	if textOnly {
+		return e[1], nil, labels, options, nil
 	}
+	if len(emojis) == 0 {
+		return "", nil, nil, nil, fmt.Errorf(ErrorTextWrongFormat)
+	}
+	if !labeled {
+		labels = nil
+	}
+	return e[1], emojis, labels, options, nil
+}
+
+// MaxTextOptions is the maximum number of options given as text only. It is the number of letterEmojis.
+const MaxTextOptions = 26
+
+// numberEmojis are assigned to text options, if there are at most as many options as numbers
+var numberEmojis = []string{"one", "two", "three", "four", "five", "six", "seven", "eight", "nine", "keycap_ten"}
+
+// letterEmojis are assigned to text options, if there are more options than numberEmojis
+var letterEmojis = func() []string {
 	var emojis []string
-	for _, v := range strings.Split(e[2], " ") {
-		if len(v) == 0 {
-			continue
+	for c := 'a'; c <= 'z'; c++ {
+		emojis = append(emojis, "regional_indicator_"+string(c))
+	}
+	return emojis
+}()
+
+// numberOptions assigns an emoji to every label of p. Up to ten options are numbered, more options get letters.
+func (p *Request) numberOptions() error {
+	emojis := numberEmojis
+	if len(p.Labels) > len(numberEmojis) {
+		emojis = letterEmojis
+	}
+	if len(p.Labels) > MaxTextOptions {
+		return fmt.Errorf(ErrorTooManyOptions, MaxTextOptions)
+	}
+	p.Emojis = emojis[:len(p.Labels)]
+	return nil
+}
+
+// splitWords splits s at spaces. Words in quotes may contain spaces and keep their quotes.
+func splitWords(s string) ([]string, error) {
+	var words []string
+	for {
+		s = strings.TrimLeft(s, " \t\n")
+		if len(s) == 0 {
+			return words, nil
+		}
+		end := strings.IndexAny(s, " \t\n")
+		if strings.ContainsAny(s[:1], "`'\"") {
+			closing := strings.IndexByte(s[1:], s[0])
+			if closing < 0 {
+				return nil, fmt.Errorf(ErrorTextWrongFormat)
+			}
+			end = closing + 2
+		}
+		if end < 0 {
+			end = len(s)
+		}
+		words = append(words, s[:end])
+		s = s[end:]
+	}
+}
+
+// isQuoted reports whether word starts and ends with the same quote
+func isQuoted(word string) bool {
+	return len(word) >= 2 && strings.ContainsAny(word[:1], "`'\"") && word[len(word)-1] == word[0]
+}
+
+// parseOptions applies the options given after the emojis to p. now is used to calculate the deadline of the poll.
+func (p *Request) parseOptions(options []string, now time.Time) error {
+	for _, o := range options {
+		kv := strings.SplitN(strings.TrimPrefix(o, "--"), "=", 2)
+		if len(kv) == 1 {
+			switch kv[0] {
+			case "single":
+				p.Single = true
+				continue
+			case "anonymous":
+				p.Anonymous = true
+				continue
+			}
+		}
+		if len(kv) != 2 || !p.Until.IsZero() {
+			return fmt.Errorf(ErrorInvalidOption)
+		}
+		switch kv[0] {
+		case "duration":
+			d, err := time.ParseDuration(kv[1])
+			if err != nil {
+				return fmt.Errorf(ErrorInvalidOption)
+			}
+			p.Until = now.Add(d)
+		case "until":
+			t, err := parseDeadline(kv[1])
+			if err != nil {
+				return fmt.Errorf(ErrorInvalidOption)
+			}
+			p.Until = t
+		default:
+			return fmt.Errorf(ErrorInvalidOption)
 		}
-		if len(v) < 3 || !strings.HasPrefix(v, ":") || !strings.HasSuffix(v, ":") {
-			return "", nil, fmt.Errorf(ErrorTextWrongFormat)
+		if !p.Until.After(now) {
+			return fmt.Errorf(ErrorDeadlinePassed)
 		}
-		emojis = append(emojis, v[1:len(v)-1])
 	}
-	return e[1], emojis, nil
+	return nil
+}
+
+// deadlineLayouts are the layouts accepted by --until. Layouts without time zone are interpreted in the local time zone.
+var deadlineLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04",
+	"2006-01-02",
+}
+
+func parseDeadline(value string) (time.Time, error) {
+	var err error
+	for _, layout := range deadlineLayouts {
+		var t time.Time
+		if t, err = time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, err
+}
+
+// errTokenLength is returned, if the token of a request has a wrong length. It is counted as a token mismatch.
+var errTokenLength = errors.New(ErrorWrongLength)
+
+// checkTokenLength checks the length of the token of a request
+func checkTokenLength(token string) error {
+	if len(token) != 26 {
+		return errTokenLength
+	}
+	return nil
 }
 
 func checkIDLength(id string) error {
@@ -1,7 +1,10 @@
 package poll_test
 
 import (
+	"fmt"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/kaakaa/matterpoll-emoji/poll"
 	"github.com/mattermost/mattermost-server/model"
@@ -49,6 +52,7 @@ func TestNewPollRequest(t *testing.T) {
 		s := make(map[string][]string)
 		s["channel_id"] = []string{test.ChannelID}
 		s["token"] = []string{test.Token}
+		s["user_id"] = []string{model.NewId()}
 		s["text"] = []string{test.Text}
 
 		p, err := poll.NewRequest(s)
@@ -66,3 +70,362 @@ func TestNewPollRequest(t *testing.T) {
 		}
 	}
 }
+
+func TestNewPollRequestLabels(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	tests := []struct {
+		Text        string
+		Emojis      []string
+		Labels      []string
+		ShouldError bool
+	}{
+		{"\"Where?\" :one: \"Thai place\" :two: \"Burger bar\"", []string{"one", "two"}, []string{"Thai place", "Burger bar"}, false},
+		{"\"Where?\" :one: 'Thai place' :two:  `Burger bar` --single", []string{"one", "two"}, []string{"Thai place", "Burger bar"}, false},
+		{"\"Where?\" :one: \"Thai place\" :two:", []string{"one", "two"}, []string{"Thai place", ""}, false},
+		{"\"Where?\" :one: :two:", []string{"one", "two"}, nil, false},
+
+		{"\"Where?\" :one: \"Thai place\" \"Burger bar\"", nil, nil, true},
+		{"\"Where?\" :one: \"Thai place", nil, nil, true},
+		{"\"Where?\" :one: \"\"", nil, nil, true},
+		{"\"Where?\" :one: --single \"Thai place\"", nil, nil, true},
+	}
+
+	for _, test := range tests {
+		s := make(map[string][]string)
+		s["channel_id"] = []string{model.NewId()}
+		s["token"] = []string{model.NewId()}
+		s["user_id"] = []string{model.NewId()}
+		s["text"] = []string{test.Text}
+
+		p, err := poll.NewRequest(s)
+		if test.ShouldError {
+			assert.NotNil(err, test.Text)
+			assert.Nil(p)
+		} else {
+			assert.Nil(err, test.Text)
+			require.NotNil(p)
+
+			assert.Equal("Where?", p.Message)
+			assert.Equal(test.Emojis, p.Emojis)
+			assert.Equal(test.Labels, p.Labels)
+		}
+	}
+}
+
+func TestNewPollRequestTextOptions(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	texts := func(n int) string {
+		var options []string
+		for i := 1; i <= n; i++ {
+			options = append(options, fmt.Sprintf("\"Option %d\"", i))
+		}
+		return strings.Join(options, " ")
+	}
+	tests := []struct {
+		Text        string
+		Emojis      []string
+		Labels      []string
+		ShouldError bool
+	}{
+		{"\"Lunch?\" \"Pizza\" \"Sushi\" \"Tacos\"", []string{"one", "two", "three"}, []string{"Pizza", "Sushi", "Tacos"}, false},
+		{"\"Lunch?\" 'Pizza' `Fried shrimp` --single", []string{"one", "two"}, []string{"Pizza", "Fried shrimp"}, false},
+		{"\"Lunch?\" " + texts(10), []string{"one", "two", "three", "four", "five", "six", "seven", "eight", "nine", "keycap_ten"}, nil, false},
+		{"\"Lunch?\" " + texts(11), []string{"regional_indicator_a", "regional_indicator_b"}, nil, false},
+		{"\"Lunch?\" " + texts(poll.MaxTextOptions), []string{"regional_indicator_a", "regional_indicator_b"}, nil, false},
+
+		{"\"Lunch?\" \"Pizza\" :sushi:", nil, nil, true},
+		{"\"Lunch?\" \"Pizza\" \"\"", nil, nil, true},
+		{"\"Lunch?\" " + texts(poll.MaxTextOptions+1), nil, nil, true},
+	}
+
+	for _, test := range tests {
+		s := make(map[string][]string)
+		s["channel_id"] = []string{model.NewId()}
+		s["token"] = []string{model.NewId()}
+		s["user_id"] = []string{model.NewId()}
+		s["text"] = []string{test.Text}
+
+		p, err := poll.NewRequest(s)
+		if test.ShouldError {
+			assert.NotNil(err, test.Text)
+			assert.Nil(p)
+			continue
+		}
+		assert.Nil(err, test.Text)
+		require.NotNil(p)
+		assert.Equal("Lunch?", p.Message)
+		require.Equal(len(p.Emojis), len(p.Labels))
+		if test.Labels != nil {
+			assert.Equal(test.Emojis, p.Emojis)
+			assert.Equal(test.Labels, p.Labels)
+		} else {
+			assert.Equal(test.Emojis, p.Emojis[:len(test.Emojis)])
+			assert.Equal("Option 1", p.Labels[0])
+		}
+	}
+
+	s := map[string][]string{"user_id": {model.NewId()}, "text": {"\"Lunch?\" " + texts(poll.MaxTextOptions+1)}}
+	_, err := poll.NewRequest(s)
+	require.NotNil(err)
+	assert.Equal(fmt.Sprintf(poll.ErrorTooManyOptions, poll.MaxTextOptions), err.Error())
+}
+
+func TestNewPollRequestUnicodeEmojis(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	tests := []struct {
+		Text        string
+		Emojis      []string
+		ShouldError bool
+	}{
+		{"\"Lunch?\" 🍕 🍣", []string{"pizza", "sushi"}, false},
+		{"\"Lunch?\" 🍕🍣 :taco:", []string{"pizza", "sushi", "taco"}, false},
+		{"\"Vote\" 👍 👎🏿", []string{"+1", "-1_dark_skin_tone"}, false},
+		{"\"Vote\" \u2764\uFE0F \u0031\uFE0F\u20E3", []string{"heart", "one"}, false},
+		{"\"Who?\" 👨\u200D💻 👩🏽\u200D💻 🏳\uFE0F\u200D🌈", []string{"male-technologist", "female-technologist_medium_skin_tone", "rainbow-flag"}, false},
+		{"\"Trip?\" 🇯🇵 🇨🇭🇺🇸 🇬🇧", []string{"jp", "flag-ch", "us", "gb"}, false},
+		{"\"Letter?\" 🇦 🇿", []string{"regional_indicator_a", "regional_indicator_z"}, false},
+		{"\"When?\" 🕐 🕜 🕛", []string{"clock1", "clock130", "clock12"}, false},
+		{"\"Who?\" 👨\u200D👩\u200D👧 👩\u200D👩\u200D👦\u200D👦 👩\u200D\u2764\uFE0F\u200D👨", []string{"man-woman-girl", "woman-woman-boy-boy", "woman-heart-man"}, false},
+		{"\"Party?\" 🥳 👩🏽\u200D\u2695\uFE0F 🙋\u200D\u2642\uFE0F", []string{"partying_face", "female-doctor_medium_skin_tone", "man-raising-hand"}, false},
+
+		{"\"Lunch?\" 🍕 pizza", nil, true},
+		{"\"Lunch?\" \u2603\U0001F3FB", nil, true},
+		{"\"Lunch?\" 👍🏿🏻", nil, true},
+		{"\"Lunch?\" ©", nil, true},
+		{"\"Trip?\" 🇦🇦", nil, true},
+	}
+
+	for _, test := range tests {
+		s := make(map[string][]string)
+		s["channel_id"] = []string{model.NewId()}
+		s["token"] = []string{model.NewId()}
+		s["user_id"] = []string{model.NewId()}
+		s["text"] = []string{test.Text}
+
+		p, err := poll.NewRequest(s)
+		if test.ShouldError {
+			assert.NotNil(err, test.Text)
+			assert.Nil(p)
+		} else {
+			assert.Nil(err, test.Text)
+			require.NotNil(p)
+			assert.Equal(test.Emojis, p.Emojis)
+		}
+	}
+
+	_, err := poll.NewRequest(map[string][]string{"user_id": {model.NewId()}, "text": {"\"Lunch?\" 🍕 ©"}})
+	require.NotNil(err)
+	assert.Equal(fmt.Sprintf(poll.ErrorUnknownUnicodeEmoji, "©"), err.Error())
+}
+
+func TestNewPollRequestEmojiNames(t *testing.T) {
+	assert := assert.New(t)
+	for _, name := range []string{":../../users/me:", ":a?b=1:", ":pizza/sushi:", ":Pizza:", ":a%2Fb:"} {
+		s := map[string][]string{
+			"user_id":    {model.NewId()},
+			"channel_id": {model.NewId()},
+			"token":      {model.NewId()},
+			"text":       {"\"Lunch?\" :pizza: " + name},
+		}
+		p, err := poll.NewRequest(s)
+		assert.Nil(p, name)
+		if assert.NotNil(err, name) {
+			assert.Equal(fmt.Sprintf(poll.ErrorInvalidEmojiName, name), err.Error())
+		}
+	}
+
+	p, err := poll.NewRequest(map[string][]string{"user_id": {model.NewId()}, "text": {"\"Vote\" :+1: :-1: :thumbsup_all-4:"}})
+	assert.Nil(err)
+	if assert.NotNil(p) {
+		assert.Equal([]string{"+1", "-1", "thumbsup_all-4"}, p.Emojis)
+	}
+}
+
+func TestNewPollRequestContext(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	s := map[string][]string{
+		"user_id":      {model.NewId()},
+		"team_id":      {model.NewId()},
+		"channel_id":   {model.NewId()},
+		"root_id":      {model.NewId()},
+		"user_name":    {"alice"},
+		"channel_name": {"town-square"},
+		"team_domain":  {"example"},
+		"token":        {model.NewId()},
+		"text":         {"\"Lunch?\" :pizza:"},
+	}
+	p, err := poll.NewRequest(s)
+	require.Nil(err)
+	require.NotNil(p)
+	assert.Equal(s["user_id"][0], p.UserID)
+	assert.Equal(s["team_id"][0], p.TeamID)
+	assert.Equal(s["channel_id"][0], p.ChannelID)
+	assert.Equal(s["root_id"][0], p.RootID)
+	assert.Equal("alice", p.UserName)
+	assert.Equal("town-square", p.ChannelName)
+	assert.Equal("example", p.TeamDomain)
+
+	// Outside of threads the root id is empty
+	s["root_id"] = []string{""}
+	p, err = poll.NewRequest(s)
+	require.Nil(err)
+	assert.Empty(p.RootID)
+
+	s["root_id"] = []string{"abc"}
+	p, err = poll.NewRequest(s)
+	assert.NotNil(err)
+	assert.Nil(p)
+}
+
+func TestNewEndRequest(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	pollID := model.NewId()
+	tests := []struct {
+		UserID      string
+		Token       string
+		Text        string
+		PollID      string
+		ShouldError bool
+	}{
+		{model.NewId(), model.NewId(), "end " + pollID, pollID, false},
+		{model.NewId(), model.NewId(), "  end   " + pollID + " ", pollID, false},
+
+		{model.NewId(), model.NewId(), "end", "", true},
+		{model.NewId(), model.NewId(), "end " + pollID + " " + pollID, "", true},
+		{model.NewId(), model.NewId(), "stop " + pollID, "", true},
+		{"", model.NewId(), "end " + pollID, "", true},
+		{model.NewId(), "", "end " + pollID, "", true},
+	}
+
+	for _, test := range tests {
+		s := make(map[string][]string)
+		s["user_id"] = []string{test.UserID}
+		s["token"] = []string{test.Token}
+		s["text"] = []string{test.Text}
+
+		p, err := poll.NewEndRequest(s)
+		if test.ShouldError {
+			assert.NotNil(err)
+			assert.Nil(p)
+		} else {
+			assert.Nil(err)
+			require.NotNil(p)
+
+			assert.Equal(test.UserID, p.UserID)
+			assert.Equal(test.Token, p.Token)
+			assert.Equal(test.PollID, p.PollID)
+		}
+	}
+}
+
+func TestRequestsRequireUser(t *testing.T) {
+	assert := assert.New(t)
+
+	// Without the user anybody could end a poll, which was created without a user
+	p, err := poll.NewRequest(map[string][]string{"token": {model.NewId()}, "channel_id": {model.NewId()}, "text": {"\"Lunch?\" :pizza:"}})
+	assert.Nil(p)
+	if assert.NotNil(err) {
+		assert.Equal(poll.ErrorWrongLength, err.Error())
+	}
+	e, err := poll.NewEndRequest(map[string][]string{"token": {model.NewId()}, "text": {"end " + model.NewId()}})
+	assert.Nil(e)
+	if assert.NotNil(err) {
+		assert.Equal(poll.ErrorWrongLength, err.Error())
+	}
+}
+
+func TestNewCommandRequest(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	tests := []struct {
+		Token       string
+		Text        string
+		Command     string
+		Args        []string
+		ShouldError bool
+	}{
+		{model.NewId(), "help", "help", []string{}, false},
+		{model.NewId(), "  help   end ", "help", []string{"end"}, false},
+		{model.NewId(), "results abc", "results", []string{"abc"}, false},
+		{model.NewId(), "", "", nil, false},
+		{"", "help", "", nil, true},
+	}
+
+	for _, test := range tests {
+		s := make(map[string][]string)
+		s["user_id"] = []string{model.NewId()}
+		s["token"] = []string{test.Token}
+		s["text"] = []string{test.Text}
+
+		p, err := poll.NewCommandRequest(s)
+		if test.ShouldError {
+			assert.NotNil(err)
+			assert.Nil(p)
+		} else {
+			assert.Nil(err)
+			require.NotNil(p)
+
+			assert.Equal(test.Token, p.Token)
+			assert.Equal(test.Command, p.Command)
+			assert.Equal(test.Args, p.Args)
+		}
+	}
+}
+
+func TestNewPollRequestOptions(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	now := time.Now()
+	tomorrow := now.Add(24 * time.Hour).Truncate(time.Minute)
+	tests := []struct {
+		Text        string
+		Emojis      []string
+		Until       time.Time
+		ShouldError bool
+	}{
+		{"`description` :emoji1: :emoji2: --duration=2h", []string{"emoji1", "emoji2"}, now.Add(2 * time.Hour), false},
+		{"`description` :emoji1: --until=" + tomorrow.Format("2006-01-02T15:04"), []string{"emoji1"}, tomorrow, false},
+		{"`description` :emoji1: --until=" + tomorrow.Format(time.RFC3339), []string{"emoji1"}, tomorrow, false},
+		{"`description` :emoji1:", []string{"emoji1"}, time.Time{}, false},
+		{"`description` :emoji1: --single --duration=2h", []string{"emoji1"}, now.Add(2 * time.Hour), false},
+
+		{"`description` :emoji1: --single=yes", nil, time.Time{}, true},
+		{"`description` --duration=2h", nil, time.Time{}, true},
+		{"`description` :emoji1: --duration=2h :emoji2:", nil, time.Time{}, true},
+		{"`description` :emoji1: --duration=2h --until=" + tomorrow.Format(time.RFC3339), nil, time.Time{}, true},
+		{"`description` :emoji1: --duration=-2h", nil, time.Time{}, true},
+		{"`description` :emoji1: --duration=soon", nil, time.Time{}, true},
+		{"`description` :emoji1: --duration", nil, time.Time{}, true},
+		{"`description` :emoji1: --until=2001-01-01T10:00", nil, time.Time{}, true},
+		{"`description` :emoji1: --until=tomorrow", nil, time.Time{}, true},
+		{"`description` :emoji1: --forever=true", nil, time.Time{}, true},
+	}
+
+	for _, test := range tests {
+		s := make(map[string][]string)
+		s["channel_id"] = []string{model.NewId()}
+		s["token"] = []string{model.NewId()}
+		s["user_id"] = []string{model.NewId()}
+		s["text"] = []string{test.Text}
+
+		p, err := poll.NewRequest(s)
+		if test.ShouldError {
+			assert.NotNil(err, test.Text)
+			assert.Nil(p)
+		} else {
+			assert.Nil(err, test.Text)
+			require.NotNil(p)
+
+			assert.Equal(test.Emojis, p.Emojis)
+			assert.WithinDuration(test.Until, p.Until, time.Second)
+			assert.Equal(strings.Contains(test.Text, "--single"), p.Single)
+		}
+	}
+}
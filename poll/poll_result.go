@@ -0,0 +1,136 @@
+package poll
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/mattermost/mattermost-server/model"
+)
+
+// errPollClosed is returned by closePoll, if the poll was already closed
+var errPollClosed = errors.New("poll already closed")
+
+// Result is the number of votes for one option of a poll
+type Result struct {
+	Emoji string
+	Votes int
+}
+
+// closePoll counts the votes for the poll with the given id, posts the results as a reply to the poll and marks the poll as closed.
+// botID is the id of the user who added the initial reactions.
+func (ps *Server) closePoll(lg *Logger, c *model.Client4, botID string, id string) error {
+	defer ps.lockPoll(id)()
+
+	p, err := ps.Store.Get(id)
+	if err != nil {
+		return err
+	}
+	if p.State == PollStateClosed {
+		return errPollClosed
+	}
+	var results []Result
+	if p.Anonymous {
+		results = countAnonymousVotes(p)
+	} else {
+		reactions, apiResponse := c.GetReactions(p.PostID)
+		if err := checkResponse(apiResponse, http.StatusOK, "Failed to fetch reactions"); err != nil {
+			return err
+		}
+		results = countVotes(p, reactions, botID)
+	}
+	_, apiResponse := c.CreatePost(&model.Post{
+		ChannelId: p.ChannelID,
+		RootId:    p.threadID(),
+		Message:   formatResults(p, results),
+	})
+	if err := checkResponse(apiResponse, http.StatusCreated, "Failed to post results"); err != nil {
+		return err
+	}
+	p.State = PollStateClosed
+	if err := ps.Store.Save(p); err != nil {
+		return err
+	}
+	// Show the final results in the poll post
+	lg.Info("Poll closed")
+	if err := ps.updatePost(c, p, results); err != nil {
+		lg.Warn("Failed to show the final results in the poll post", "err", err)
+	}
+	return nil
+}
+
+// threadID returns the id of the thread replies to the poll are posted in. It is the poll post itself, unless the poll is a reply.
+func (p *Poll) threadID() string {
+	if len(p.RootID) != 0 {
+		return p.RootID
+	}
+	return p.PostID
+}
+
+// optionText renders the option emoji of p followed by its label, if it has one
+func (p *Poll) optionText(emoji string) string {
+	for i, o := range p.Options {
+		if o == emoji && i < len(p.Labels) && len(p.Labels[i]) != 0 {
+			return fmt.Sprintf(":%s: %s", emoji, p.Labels[i])
+		}
+	}
+	return ":" + emoji + ":"
+}
+
+// countVotes counts the reactions for every option of p. Reactions with other emojis and the reactions added by botID are ignored.
+// For single choice polls only the latest vote of every user is counted.
+func countVotes(p *Poll, reactions []*model.Reaction, botID string) []Result {
+	latest := make(map[string]*model.Reaction)
+	votes := make(map[string]int)
+	for _, r := range reactions {
+		if r.UserId == botID || !p.hasOption(r.EmojiName) {
+			continue
+		}
+		if p.Single {
+			if l, ok := latest[r.UserId]; !ok || r.CreateAt > l.CreateAt {
+				latest[r.UserId] = r
+			}
+			continue
+		}
+		votes[r.EmojiName]++
+	}
+	for _, r := range latest {
+		votes[r.EmojiName]++
+	}
+	results := make([]Result, len(p.Options))
+	for i, o := range p.Options {
+		results[i] = Result{Emoji: o, Votes: votes[o]}
+	}
+	return results
+}
+
+// formatResults renders results as a markdown table with the number and percentage of votes for each option
+func formatResults(p *Poll, results []Result) string {
+	total := 0
+	for _, r := range results {
+		total += r.Votes
+	}
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "Poll **%s** has ended with %d %s.\n\n", p.Question, total, pluralize(total, "vote", "votes"))
+	b.WriteString("| Option | Votes | Percentage |\n")
+	b.WriteString("|:------:|------:|-----------:|\n")
+	for _, r := range results {
+		fmt.Fprintf(&b, "| %s | %d | %s |\n", p.optionText(r.Emoji), r.Votes, percentage(r.Votes, total))
+	}
+	return b.String()
+}
+
+func percentage(votes, total int) string {
+	if total == 0 {
+		return "0%"
+	}
+	return fmt.Sprintf("%.0f%%", float64(votes)*100/float64(total))
+}
+
+func pluralize(n int, singular, plural string) string {
+	if n == 1 {
+		return singular
+	}
+	return plural
+}
@@ -0,0 +1,98 @@
+package poll_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/kaakaa/matterpoll-emoji/poll"
+	"github.com/mattermost/mattermost-server/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommandEnd(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ps, mm := newTestServer(require)
+	defer mm.Close()
+	c := ps.Conf()
+
+	creator := model.NewId()
+	p := createTestPoll(require, mm, ps, creator, ":pizza: :sushi: :apple:")
+	mm.AddReaction(p.PostID, model.NewId(), "pizza")
+	mm.AddReaction(p.PostID, model.NewId(), "pizza")
+	mm.AddReaction(p.PostID, model.NewId(), "pizza")
+	mm.AddReaction(p.PostID, model.NewId(), "sushi")
+	mm.AddReaction(p.PostID, model.NewId(), "tada")
+
+	// Only the creator may end the poll
+	payload := fmt.Sprintf("token=%s&user_id=%s&text=end %s", c.Token, model.NewId(), p.ID)
+	response, _ := sendHttpRequest(require, ps, payload)
+	assert.Equal(model.COMMAND_RESPONSE_TYPE_EPHEMERAL, response.ResponseType)
+	assert.Equal(poll.ErrorNotCreator, response.Text)
+
+	payload = fmt.Sprintf("token=%s&user_id=%s&text=end %s", c.Token, creator, p.ID)
+	response, _ = sendHttpRequest(require, ps, payload)
+	assert.Equal(model.COMMAND_RESPONSE_TYPE_EPHEMERAL, response.ResponseType)
+	assert.Equal(poll.ResponseTextEnded, response.Text)
+
+	posts := mm.Posts()
+	require.Len(posts, 2)
+	assert.Equal(p.PostID, posts[1].RootId)
+	assert.Equal(p.ChannelID, posts[1].ChannelId)
+	assert.Contains(posts[1].Message, "with 4 votes")
+	assert.Contains(posts[1].Message, "| :pizza: | 3 | 75% |")
+	assert.Contains(posts[1].Message, "| :sushi: | 1 | 25% |")
+	assert.Contains(posts[1].Message, "| :apple: | 0 | 0% |")
+	assert.NotContains(posts[1].Message, "tada")
+
+	stored, err := ps.Store.Get(p.ID)
+	require.Nil(err)
+	assert.Equal(poll.PollStateClosed, stored.State)
+
+	response, _ = sendHttpRequest(require, ps, payload)
+	assert.Equal(poll.ErrorPollClosed, response.Text)
+	assert.Len(mm.Posts(), 2)
+}
+
+func TestCommandEndErrors(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	c, err := getConfig("sample_conf.json")
+	require.Nil(err)
+	ps, err := poll.NewServer(c)
+	require.Nil(err)
+
+	tests := []struct {
+		Token    string
+		Text     string
+		Expected string
+	}{
+		{c.Token, "end " + model.NewId(), poll.ErrorPollNotFound},
+		{c.Token, "end", poll.ErrorEndWrongFormat},
+		{c.Token, "end one two", poll.ErrorEndWrongFormat},
+		{model.NewId(), "end " + model.NewId(), poll.ErrorTokenMissmatch},
+	}
+	for _, test := range tests {
+		payload := fmt.Sprintf("token=%s&user_id=%s&text=%s", test.Token, model.NewId(), test.Text)
+		response, _ := sendHttpRequest(require, ps, payload)
+		assert.Equal(model.COMMAND_RESPONSE_TYPE_EPHEMERAL, response.ResponseType)
+		assert.Equal(test.Expected, response.Text)
+	}
+}
+
+// createTestPoll creates a poll with emojis through the slash command and waits until all reactions are added
+func createTestPoll(require *require.Assertions, mm *fakeMattermost, ps *poll.Server, creator string, emojis string) *poll.Poll {
+	before, err := ps.Store.List()
+	require.Nil(err)
+	payload := fmt.Sprintf("token=%s&user_id=%s&channel_id=%s&text=\"%s\" %s", ps.Conf().Token, creator, model.NewId(), "What do you gys wanna grab for lunch?", emojis)
+	sendHttpRequest(require, ps, payload)
+	polls, err := ps.Store.List()
+	require.Nil(err)
+	require.Len(polls, len(before)+1)
+	p := polls[len(polls)-1]
+	require.True(waitFor(func() bool { return len(mm.Reactions(p.PostID)) == len(p.Options) }))
+	return p
+}
@@ -0,0 +1,77 @@
+package poll
+
+import (
+	"time"
+
+	"github.com/mattermost/mattermost-server/model"
+)
+
+// schedulerRetryInterval is the time the scheduler waits before it tries again to close a poll, if closing failed
+const schedulerRetryInterval = time.Minute
+
+// runScheduler closes expired polls until Stop is called. It sleeps until the next deadline of an open poll or until it is woken up by reschedule.
+func (ps *Server) runScheduler() {
+	for {
+		next := ps.closeExpired(time.Now())
+		var timer *time.Timer
+		var fire <-chan time.Time
+		if !next.IsZero() {
+			timer = time.NewTimer(time.Until(next))
+			fire = timer.C
+		}
+		select {
+		case <-fire:
+		case <-ps.wakeup:
+		case <-ps.stop:
+			if timer != nil {
+				timer.Stop()
+			}
+			return
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+// reschedule wakes up the scheduler to pick up a new deadline
+func (ps *Server) reschedule() {
+	select {
+	case ps.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+// closeExpired closes all open polls whose deadline is before now and returns the next time the scheduler has to run.
+// It returns the zero time, if no open poll has a deadline.
+func (ps *Server) closeExpired(now time.Time) time.Time {
+	lg := ps.Logger()
+	polls, err := ps.Store.List()
+	if err != nil {
+		lg.Error("Failed to list polls", "err", err)
+		return now.Add(schedulerRetryInterval)
+	}
+	var next time.Time
+	for _, p := range polls {
+		if p.State != PollStateOpen || p.ExpiresAt.IsZero() {
+			continue
+		}
+		deadline := p.ExpiresAt
+		if !deadline.After(now) {
+			id := p.ID
+			plg := lg.With("poll_id", id, "instance", p.Instance)
+			err := ps.withClient(plg, p.Instance, func(c *model.Client4, bot *model.User) error {
+				return ps.closePoll(plg, c, bot.Id, id)
+			})
+			if err == nil || err == errPollClosed {
+				continue
+			}
+			plg.Error("Failed to close expired poll", "err", err)
+			deadline = now.Add(schedulerRetryInterval)
+		}
+		if next.IsZero() || deadline.Before(next) {
+			next = deadline
+		}
+	}
+	return next
+}
@@ -0,0 +1,67 @@
+package poll_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kaakaa/matterpoll-emoji/poll"
+	"github.com/mattermost/mattermost-server/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchedulerClosesExpiredPoll(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ps, mm := newTestServer(require)
+	defer mm.Close()
+	ps.Start()
+	defer ps.Stop()
+
+	p := createTestPoll(require, mm, ps, model.NewId(), ":pizza: :sushi: --duration=200ms")
+	assert.False(p.ExpiresAt.IsZero())
+	assert.Contains(mm.Posts()[0].Message, "This poll ends at")
+	mm.AddReaction(p.PostID, model.NewId(), "sushi")
+
+	require.True(waitFor(func() bool {
+		stored, err := ps.Store.Get(p.ID)
+		return err == nil && stored.State == poll.PollStateClosed
+	}))
+	posts := mm.Posts()
+	require.Len(posts, 2)
+	assert.Equal(p.PostID, posts[1].RootId)
+	assert.Contains(posts[1].Message, "| :sushi: | 1 | 100% |")
+}
+
+func TestSchedulerReschedulesOnStart(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ps, mm := newTestServer(require)
+	defer mm.Close()
+
+	// Polls saved before the server was started
+	expired := newTestPoll(time.Now().Add(-time.Hour))
+	expired.ExpiresAt = time.Now().Add(-time.Minute)
+	pending := newTestPoll(time.Now().Add(-time.Hour))
+	pending.ExpiresAt = time.Now().Add(300 * time.Millisecond)
+	open := newTestPoll(time.Now().Add(-time.Hour))
+	for _, p := range []*poll.Poll{expired, pending, open} {
+		require.Nil(ps.Store.Save(p))
+	}
+
+	ps.Start()
+	defer ps.Stop()
+
+	state := func(id string) poll.PollState {
+		p, err := ps.Store.Get(id)
+		require.Nil(err)
+		return p.State
+	}
+	assert.True(waitFor(func() bool { return state(expired.ID) == poll.PollStateClosed }))
+	assert.Equal(poll.PollStateOpen, state(pending.ID))
+	assert.True(waitFor(func() bool { return state(pending.ID) == poll.PollStateClosed }))
+	assert.Equal(poll.PollStateOpen, state(open.ID))
+	assert.Len(mm.Posts(), 2)
+}
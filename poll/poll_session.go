@@ -0,0 +1,127 @@
+package poll
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/mattermost/mattermost-server/model"
+)
+
+// APIError is returned, if a request to the Mattermost API fails
+type APIError struct {
+	Action     string
+	StatusCode int
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("Error: %s. API statuscode: %v", e.Action, e.StatusCode)
+}
+
+// checkResponse returns an APIError for action, if r doesn't have the expected status code
+func checkResponse(r *model.Response, expected int, action string) error {
+	if r != nil && r.StatusCode != expected {
+		return &APIError{Action: action, StatusCode: r.StatusCode}
+	}
+	return nil
+}
+
+// session is the login of the bot user on one instance. It is shared by all requests handled by a Server.
+type session struct {
+	mu     sync.Mutex
+	client *model.Client4
+	user   *model.User
+}
+
+// session returns the session of the instance called name
+func (ps *Server) session(name string) *session {
+	ps.sessionsMu.Lock()
+	defer ps.sessionsMu.Unlock()
+	s, ok := ps.sessions[name]
+	if !ok {
+		s = &session{}
+		ps.sessions[name] = s
+	}
+	return s
+}
+
+// client returns the logged in client of the bot user on the instance called name. The bot user logs in, if there is no session yet.
+// If an access token is configured, it is used instead of logging in.
+// The returned client must not be modified, because it is used concurrently.
+func (ps *Server) client(lg *Logger, name string) (*model.Client4, *model.User, error) {
+	s := ps.session(name)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.client != nil {
+		return s.client, s.user, nil
+	}
+	in, ok := ps.Conf().instance(name)
+	if !ok {
+		return nil, nil, fmt.Errorf("Error: Unknown Mattermost instance %q", name)
+	}
+	c := model.NewAPIv4Client(in.Host)
+	c.HttpClient = &http.Client{Timeout: time.Duration(ps.Conf().APITimeout)}
+	user, err := login(c, in)
+	ps.metrics.countLogin(err)
+	if err != nil {
+		lg.Error("Bot user failed to log in", "host", in.Host, "err", err)
+		return nil, nil, err
+	}
+	lg.Info("Bot user logged in", "host", in.Host, "user_id", user.Id)
+	s.client, s.user = c, user
+	return c, user, nil
+}
+
+// expire drops the session of c on the instance called name, so that the next call of client logs in again.
+// Nothing happens, if another request already replaced the session of c.
+func (ps *Server) expire(name string, c *model.Client4) {
+	s := ps.session(name)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.client == c {
+		s.client, s.user = nil, nil
+	}
+}
+
+// dropSessions forgets the sessions of all instances, so that the next call of client logs in again
+func (ps *Server) dropSessions() {
+	ps.sessionsMu.Lock()
+	defer ps.sessionsMu.Unlock()
+	ps.sessions = make(map[string]*session)
+}
+
+// withClient calls fn with the shared client of the bot user on the instance called name. If fn fails because the session has expired,
+// the bot user logs in again and fn is called once more.
+func (ps *Server) withClient(lg *Logger, name string, fn func(c *model.Client4, bot *model.User) error) error {
+	for try := 0; ; try++ {
+		c, user, err := ps.client(lg, name)
+		if err != nil {
+			return err
+		}
+		err = fn(c, user)
+		if e, ok := err.(*APIError); ok && e.StatusCode == http.StatusUnauthorized && try == 0 {
+			lg.Info("Bot session expired")
+			ps.expire(name, c)
+			continue
+		}
+		return err
+	}
+}
+
+// login authenticates c with the credentials of the bot user of the instance in
+func login(c *model.Client4, in *Instance) (*model.User, error) {
+	if len(in.AccessToken) != 0 {
+		c.SetOAuthToken(in.AccessToken)
+		u, apiResponse := c.GetMe("")
+		if err := checkResponse(apiResponse, http.StatusOK, "Authentication with access token failed"); err != nil {
+			return nil, err
+		}
+		return u, nil
+	}
+	u, apiResponse := c.Login(in.User.ID, in.User.Password)
+	if err := checkResponse(apiResponse, http.StatusOK, "Login failed"); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
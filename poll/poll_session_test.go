@@ -0,0 +1,109 @@
+package poll_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kaakaa/matterpoll-emoji/poll"
+	"github.com/mattermost/mattermost-server/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionIsReused(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ps, mm := newTestServer(require)
+	defer mm.Close()
+	c := ps.Conf()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			payload := fmt.Sprintf("token=%s&user_id=%s&channel_id=%s&text=\"%s\"%s", c.Token, model.NewId(), model.NewId(), "Lunch?", ":pizza: :sushi:")
+			response, _ := sendHttpRequest(require, ps, payload)
+			assert.NotEqual(poll.ErrorPostFailed, response.Text)
+		}()
+	}
+	wg.Wait()
+
+	posts := mm.Posts()
+	require.Len(posts, 5)
+	for _, post := range posts {
+		id := post.Id
+		assert.True(waitFor(func() bool { return len(mm.Reactions(id)) == 2 }))
+	}
+	assert.Equal(1, mm.Logins())
+}
+
+func TestSessionRelogin(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ps, mm := newTestServer(require)
+	defer mm.Close()
+	c := ps.Conf()
+
+	p := createTestPoll(require, mm, ps, model.NewId(), ":pizza: :sushi:")
+	assert.True(waitFor(func() bool { return len(mm.Reactions(p.PostID)) == 2 }))
+	require.Equal(1, mm.Logins())
+
+	mm.ExpireSessions()
+	payload := fmt.Sprintf("token=%s&user_id=%s&text=end %s", c.Token, p.Creator, p.ID)
+	response, _ := sendHttpRequest(require, ps, payload)
+	assert.Equal(poll.ResponseTextEnded, response.Text)
+	assert.Equal(2, mm.Logins())
+
+	closed, err := ps.Store.Get(p.ID)
+	require.Nil(err)
+	assert.Equal(poll.PollStateClosed, closed.State)
+}
+
+func TestSessionAccessToken(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	mm := newFakeMattermost()
+	defer mm.Close()
+	c, err := getConfig("sample_conf_access_token.json")
+	require.Nil(err)
+	c.Host = mm.URL
+	c.AccessToken = mm.CreateAccessToken()
+	ps, err := poll.NewServer(c)
+	require.Nil(err)
+
+	p := createTestPoll(require, mm, ps, model.NewId(), ":pizza: :sushi:")
+	assert.True(waitFor(func() bool { return len(mm.Reactions(p.PostID)) == 2 }))
+	assert.Equal([]string{"pizza", "sushi"}, mm.UserReactions(p.PostID, mm.BotID))
+	assert.Equal(0, mm.Logins())
+
+	// A revoked access token can't be fixed by logging in again
+	mm.ExpireSessions()
+	payload := fmt.Sprintf("token=%s&user_id=%s&channel_id=%s&text=\"%s\"%s", c.Token, model.NewId(), model.NewId(), "Lunch?", ":pizza:")
+	response, _ := sendHttpRequest(require, ps, payload)
+	assert.Equal(poll.ErrorPostFailed, response.Text)
+	assert.Equal(0, mm.Logins())
+}
+
+func TestAPITimeout(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ps, mm := newTestServer(require)
+	defer mm.Close()
+	c := ps.Conf()
+	c.APITimeout = poll.Duration(50 * time.Millisecond)
+
+	// Without the timeout every attempt to add the reaction would hang for two seconds
+	mm.SetReactionDelay(2 * time.Second)
+	sendTestPoll(require, ps, ":pizza:")
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	assert.Nil(ps.Shutdown(ctx))
+}
@@ -0,0 +1,259 @@
+package poll
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// StoreTypeMemory keeps polls in memory. All polls are lost when Matterpoll stops
+	StoreTypeMemory = "memory"
+	// StoreTypeFile keeps polls in a JSON file
+	StoreTypeFile = "file"
+)
+
+// ErrPollNotFound is returned by a Storage, if no poll with the requested id exists
+var ErrPollNotFound = errors.New("poll not found")
+
+// PollState describes if a poll still accepts votes
+type PollState string
+
+const (
+	// PollStateOpen is the state of a poll which still accepts votes
+	PollStateOpen PollState = "open"
+	// PollStateClosed is the state of a poll which was ended
+	PollStateClosed PollState = "closed"
+)
+
+// Poll is the record of a poll posted by Matterpoll
+type Poll struct {
+	ID string `json:"id"`
+	// Instance is the name of the Mattermost server the poll was posted to. It is empty for the default instance.
+	Instance  string `json:"instance,omitempty"`
+	Creator   string `json:"creator"`
+	ChannelID string `json:"channel_id"`
+	PostID    string `json:"post_id"`
+	// RootID is the id of the thread the poll was posted in. It is empty, if the poll isn't a reply.
+	RootID   string   `json:"root_id,omitempty"`
+	Question string   `json:"question"`
+	Options  []string `json:"options"`
+	// Labels describe the options with the same index. It is empty, if the options have no labels.
+	Labels    []string  `json:"labels,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	// ExpiresAt is the time the poll is closed automatically. It is zero, if the poll has no deadline.
+	ExpiresAt time.Time `json:"expires_at"`
+	// Single is true, if only the last vote of every user counts
+	Single bool `json:"single"`
+	// Anonymous is true, if votes are cast with buttons instead of reactions
+	Anonymous bool `json:"anonymous"`
+	// Secret is sent with every button click to make sure the vote was cast through the poll post
+	Secret string `json:"secret,omitempty"`
+	// Votes maps the id of every user who voted in an anonymous poll to the chosen option
+	Votes map[string]string `json:"votes,omitempty"`
+	State PollState         `json:"state"`
+}
+
+// Storage saves polls and looks them up again
+type Storage interface {
+	// Save creates a poll or overwrites the poll with the same id
+	Save(p *Poll) error
+	// Get returns the poll with the given id or ErrPollNotFound
+	Get(id string) (*Poll, error)
+	// GetByPost returns the poll posted as the post with postID or ErrPollNotFound
+	GetByPost(postID string) (*Poll, error)
+	// List returns all polls ordered by their creation time
+	List() ([]*Poll, error)
+}
+
+// NewStorage creates the Storage configured in c
+func NewStorage(c *StoreConf) (Storage, error) {
+	switch c.Type {
+	case "", StoreTypeMemory:
+		return NewMemoryStorage(), nil
+	case StoreTypeFile:
+		return NewFileStorage(c.Path)
+	default:
+		return nil, fmt.Errorf("Unknown store type %q", c.Type)
+	}
+}
+
+func (p *Poll) copy() *Poll {
+	c := *p
+	c.Options = append([]string(nil), p.Options...)
+	if p.Labels != nil {
+		c.Labels = append([]string(nil), p.Labels...)
+	}
+	if p.Votes != nil {
+		c.Votes = make(map[string]string, len(p.Votes))
+		for user, option := range p.Votes {
+			c.Votes[user] = option
+		}
+	}
+	return &c
+}
+
+// MemoryStorage is a Storage which keeps all polls in memory
+type MemoryStorage struct {
+	mu    sync.RWMutex
+	polls map[string]*Poll
+}
+
+// NewMemoryStorage creates an empty MemoryStorage
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{polls: make(map[string]*Poll)}
+}
+
+// Save stores a copy of p
+func (s *MemoryStorage) Save(p *Poll) error {
+	if len(p.ID) == 0 {
+		return fmt.Errorf("Poll id is missing")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.polls[p.ID] = p.copy()
+	return nil
+}
+
+// Get returns a copy of the poll with the given id
+func (s *MemoryStorage) Get(id string) (*Poll, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	p, ok := s.polls[id]
+	if !ok {
+		return nil, ErrPollNotFound
+	}
+	return p.copy(), nil
+}
+
+// GetByPost returns a copy of the poll posted as the post with postID
+func (s *MemoryStorage) GetByPost(postID string) (*Poll, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, p := range s.polls {
+		if p.PostID == postID {
+			return p.copy(), nil
+		}
+	}
+	return nil, ErrPollNotFound
+}
+
+// List returns copies of all polls ordered by their creation time
+func (s *MemoryStorage) List() ([]*Poll, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	polls := make([]*Poll, 0, len(s.polls))
+	for _, p := range s.polls {
+		polls = append(polls, p.copy())
+	}
+	sort.Slice(polls, func(i, j int) bool {
+		return polls[i].CreatedAt.Before(polls[j].CreatedAt)
+	})
+	return polls, nil
+}
+
+func (s *MemoryStorage) delete(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.polls, id)
+}
+
+// FileStorage is a Storage which writes all polls to a JSON file on every change
+type FileStorage struct {
+	path string
+	mem  *MemoryStorage
+	mu   sync.Mutex
+}
+
+// NewFileStorage creates a FileStorage writing to path. Polls already saved in path are loaded.
+func NewFileStorage(path string) (*FileStorage, error) {
+	s := &FileStorage{path: path, mem: NewMemoryStorage()}
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var polls []*Poll
+	if err := json.Unmarshal(b, &polls); err != nil {
+		return nil, fmt.Errorf("Failed to read polls from %s: %v", path, err)
+	}
+	for _, p := range polls {
+		if err := s.mem.Save(p); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Save stores p and writes all polls to the file
+func (s *FileStorage) Save(p *Poll) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old, getErr := s.mem.Get(p.ID)
+	if err := s.mem.Save(p); err != nil {
+		return err
+	}
+	if err := s.write(); err != nil {
+		// Keep memory and file in sync
+		if getErr == nil {
+			s.mem.Save(old)
+		} else {
+			s.mem.delete(p.ID)
+		}
+		return err
+	}
+	return nil
+}
+
+// Get returns the poll with the given id
+func (s *FileStorage) Get(id string) (*Poll, error) {
+	return s.mem.Get(id)
+}
+
+// GetByPost returns the poll posted as the post with postID
+func (s *FileStorage) GetByPost(postID string) (*Poll, error) {
+	return s.mem.GetByPost(postID)
+}
+
+// List returns all polls ordered by their creation time
+func (s *FileStorage) List() ([]*Poll, error) {
+	return s.mem.List()
+}
+
+// write replaces the file with the current polls. A temporary file is renamed to make sure the file is never left half written.
+func (s *FileStorage) write() error {
+	polls, err := s.mem.List()
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(polls, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path))
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package poll_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kaakaa/matterpoll-emoji/poll"
+	"github.com/mattermost/mattermost-server/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorage(t *testing.T) {
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "matterpoll")
+	require.Nil(err)
+	defer os.RemoveAll(dir)
+
+	file, err := poll.NewFileStorage(filepath.Join(dir, "polls.json"))
+	require.Nil(err)
+
+	tests := []struct {
+		Name  string
+		Store poll.Storage
+	}{
+		{"memory", poll.NewMemoryStorage()},
+		{"file", file},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			testStorage(t, test.Store)
+		})
+	}
+}
+
+func testStorage(t *testing.T, s poll.Storage) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	first := newTestPoll(time.Now().Add(-time.Minute))
+	second := newTestPoll(time.Now())
+	require.Nil(s.Save(second))
+	require.Nil(s.Save(first))
+
+	p, err := s.Get(first.ID)
+	require.Nil(err)
+	assert.Equal(first.Question, p.Question)
+	assert.Equal(first.Options, p.Options)
+	assert.Equal(poll.PollStateOpen, p.State)
+
+	p, err = s.Get(model.NewId())
+	assert.Equal(poll.ErrPollNotFound, err)
+	assert.Nil(p)
+
+	// Changing a returned poll must not change the stored poll
+	p, err = s.Get(first.ID)
+	require.Nil(err)
+	p.State = poll.PollStateClosed
+	p.Options[0] = "changed"
+	p.Labels[0] = "changed"
+	p, err = s.Get(first.ID)
+	require.Nil(err)
+	assert.Equal(poll.PollStateOpen, p.State)
+	assert.Equal("pizza", p.Options[0])
+	assert.Equal("Pizza place", p.Labels[0])
+
+	p.State = poll.PollStateClosed
+	require.Nil(s.Save(p))
+	polls, err := s.List()
+	require.Nil(err)
+	require.Len(polls, 2)
+	assert.Equal(first.ID, polls[0].ID)
+	assert.Equal(poll.PollStateClosed, polls[0].State)
+	assert.Equal(second.ID, polls[1].ID)
+
+	assert.NotNil(s.Save(&poll.Poll{}))
+}
+
+func TestFileStorageReload(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "matterpoll")
+	require.Nil(err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "polls.json")
+
+	s, err := poll.NewFileStorage(path)
+	require.Nil(err)
+	p := newTestPoll(time.Now())
+	require.Nil(s.Save(p))
+
+	s, err = poll.NewFileStorage(path)
+	require.Nil(err)
+	loaded, err := s.Get(p.ID)
+	require.Nil(err)
+	assert.Equal(p.PostID, loaded.PostID)
+	assert.Equal(p.Options, loaded.Options)
+	assert.True(p.CreatedAt.Equal(loaded.CreatedAt))
+
+	require.Nil(ioutil.WriteFile(path, []byte("{"), 0600))
+	s, err = poll.NewFileStorage(path)
+	assert.NotNil(err)
+	assert.Nil(s)
+}
+
+func TestFileStorageWriteFailed(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "matterpoll")
+	require.Nil(err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "polls.json")
+	s, err := poll.NewFileStorage(path)
+	require.Nil(err)
+
+	// The temporary file can't replace a directory
+	require.Nil(os.MkdirAll(filepath.Join(path, "blocked"), 0700))
+	p := newTestPoll(time.Now())
+	assert.NotNil(s.Save(p))
+	_, err = s.Get(p.ID)
+	assert.Equal(poll.ErrPollNotFound, err)
+	files, err := ioutil.ReadDir(dir)
+	require.Nil(err)
+	require.Len(files, 1)
+	assert.Equal("polls.json", files[0].Name())
+}
+
+func TestNewStorage(t *testing.T) {
+	assert := assert.New(t)
+
+	s, err := poll.NewStorage(&poll.StoreConf{})
+	assert.Nil(err)
+	assert.IsType(&poll.MemoryStorage{}, s)
+
+	s, err = poll.NewStorage(&poll.StoreConf{Type: poll.StoreTypeFile, Path: filepath.Join(os.TempDir(), model.NewId())})
+	assert.Nil(err)
+	assert.IsType(&poll.FileStorage{}, s)
+
+	s, err = poll.NewStorage(&poll.StoreConf{Type: "redis"})
+	assert.NotNil(err)
+	assert.Nil(s)
+}
+
+func newTestPoll(createdAt time.Time) *poll.Poll {
+	return &poll.Poll{
+		ID:        model.NewId(),
+		Creator:   model.NewId(),
+		ChannelID: model.NewId(),
+		PostID:    model.NewId(),
+		Question:  "What do you gys wanna grab for lunch?",
+		Options:   []string{"pizza", "sushi"},
+		Labels:    []string{"Pizza place", ""},
+		CreatedAt: createdAt,
+		State:     poll.PollStateOpen,
+	}
+}
@@ -0,0 +1,171 @@
+package poll
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost-server/model"
+)
+
+// tallyBarWidth is the number of characters of a full bar in the chart of results
+const tallyBarWidth = 10
+
+// formatTally renders results as a bar chart with one line per option
+func formatTally(results []Result) string {
+	total := 0
+	for _, r := range results {
+		total += r.Votes
+	}
+	var b bytes.Buffer
+	for _, r := range results {
+		filled := 0
+		if total != 0 {
+			filled = (r.Votes*tallyBarWidth + total/2) / total
+		}
+		bar := strings.Repeat("█", filled) + strings.Repeat("░", tallyBarWidth-filled)
+		fmt.Fprintf(&b, ":%s: `%s` %d (%s)\n", r.Emoji, bar, r.Votes, percentage(r.Votes, total))
+	}
+	return b.String()
+}
+
+// pendingRefresh is a refresh of a poll post which waits for more votes
+type pendingRefresh struct {
+	timer *time.Timer
+	// done finishes the job of the refresh
+	done func()
+}
+
+// scheduleRefresh refreshes the results in the post of the poll with pollID after the configured delay.
+// Further calls until the refresh happens are ignored, so that a burst of votes causes only one update.
+func (ps *Server) scheduleRefresh(pollID string) {
+	ps.refreshMu.Lock()
+	defer ps.refreshMu.Unlock()
+	if _, ok := ps.refreshes[pollID]; ok {
+		return
+	}
+	r := &pendingRefresh{done: ps.jobs.start("refreshing poll " + pollID)}
+	r.timer = time.AfterFunc(time.Duration(ps.Conf().RefreshDelay), func() {
+		ps.refreshMu.Lock()
+		delete(ps.refreshes, pollID)
+		ps.refreshMu.Unlock()
+		ps.runRefresh(pollID, r.done)
+	})
+	ps.refreshes[pollID] = r
+}
+
+// runRefresh refreshes the poll with pollID and calls done afterwards
+func (ps *Server) runRefresh(pollID string, done func()) {
+	defer done()
+	lg := ps.Logger().With("poll_id", pollID)
+	if err := ps.refresh(lg, pollID); err != nil {
+		lg.Error("Failed to refresh poll post", "err", err)
+	}
+}
+
+// refresh counts the votes of a poll and updates its post, if the results have changed since the last refresh.
+// The poll is locked, so that a refresh which started before the poll was closed can't overwrite its final results.
+func (ps *Server) refresh(lg *Logger, pollID string) error {
+	defer ps.lockPoll(pollID)()
+	p, err := ps.Store.Get(pollID)
+	if err != nil {
+		return err
+	}
+	if p.Anonymous || p.State == PollStateClosed {
+		// The post of an anonymous poll is updated with every vote and closed polls are updated by closePoll
+		return nil
+	}
+	results, err := ps.currentResults(lg, p)
+	if err != nil {
+		return err
+	}
+	return ps.withClient(lg, p.Instance, func(c *model.Client4, bot *model.User) error {
+		return ps.updatePost(c, p, results)
+	})
+}
+
+// updatePost replaces the message of the poll post with the current results. Nothing is sent, if the message didn't change.
+// The buttons of anonymous polls are rendered again, so that they disappear when the poll is closed.
+func (ps *Server) updatePost(c *model.Client4, p *Poll, results []Result) error {
+	message := postMessage(p, results)
+	ps.refreshMu.Lock()
+	unchanged := ps.rendered[p.ID] == message
+	ps.refreshMu.Unlock()
+	if unchanged {
+		return nil
+	}
+	patch := &model.PostPatch{Message: &message}
+	if p.Anonymous {
+		props := model.StringInterface{"attachments": ps.voteAttachments(p, results)}
+		patch.Props = &props
+	}
+	_, apiResponse := c.PatchPost(p.PostID, patch)
+	if err := checkResponse(apiResponse, http.StatusOK, "Failed to update poll post"); err != nil {
+		return err
+	}
+	ps.setRendered(p, message)
+	return nil
+}
+
+// setRendered remembers the message of the poll post. It is forgotten when the poll is closed.
+func (ps *Server) setRendered(p *Poll, message string) {
+	ps.refreshMu.Lock()
+	defer ps.refreshMu.Unlock()
+	if p.State == PollStateClosed {
+		delete(ps.rendered, p.ID)
+		return
+	}
+	ps.rendered[p.ID] = message
+}
+
+// flushRefreshes starts all pending refreshes immediately instead of waiting for more votes
+func (ps *Server) flushRefreshes() {
+	ps.refreshMu.Lock()
+	defer ps.refreshMu.Unlock()
+	for id, r := range ps.refreshes {
+		// If the timer already fired, the refresh is running anyway
+		if r.timer.Stop() {
+			go ps.runRefresh(id, r.done)
+		}
+		delete(ps.refreshes, id)
+	}
+}
+
+// runRefresher refreshes the results of all open polls in the configured interval until Stop is called.
+// It catches votes which were missed, e.g. while the websocket connection was lost.
+func (ps *Server) runRefresher() {
+	interval := time.Duration(ps.Conf().RefreshInterval)
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ps.stop:
+			return
+		case <-ticker.C:
+			ps.refreshAll()
+		}
+	}
+}
+
+func (ps *Server) refreshAll() {
+	lg := ps.Logger()
+	polls, err := ps.Store.List()
+	if err != nil {
+		lg.Error("Failed to list polls", "err", err)
+		return
+	}
+	for _, p := range polls {
+		if p.State != PollStateOpen || p.Anonymous {
+			continue
+		}
+		plg := lg.With("poll_id", p.ID)
+		if err := ps.refresh(plg, p.ID); err != nil {
+			plg.Error("Failed to refresh poll post", "err", err)
+		}
+	}
+}
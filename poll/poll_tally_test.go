@@ -0,0 +1,93 @@
+package poll_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kaakaa/matterpoll-emoji/poll"
+	"github.com/mattermost/mattermost-server/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTallyRefreshedByReactions(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ps, mm := newTestServer(require)
+	defer mm.Close()
+	c := ps.Conf()
+	c.RefreshDelay = poll.Duration(100 * time.Millisecond)
+	ps.Start()
+	defer ps.Stop()
+	require.True(waitFor(func() bool { return mm.Connected() == 1 }))
+
+	p := createTestPoll(require, mm, ps, model.NewId(), ":pizza: :sushi:")
+	// A burst of votes causes a single update
+	for i := 0; i < 5; i++ {
+		mm.AddReaction(p.PostID, model.NewId(), "pizza")
+	}
+	mm.AddReaction(p.PostID, model.NewId(), "sushi")
+	mm.AddReaction(p.PostID, model.NewId(), "sushi")
+	mm.AddReaction(p.PostID, model.NewId(), "sushi")
+
+	require.True(waitFor(func() bool {
+		return strings.Contains(mm.Post(p.PostID).Message, ":pizza: `██████░░░░` 5 (62%)")
+	}))
+	assert.Contains(mm.Post(p.PostID).Message, ":sushi: `████░░░░░░` 3 (38%)")
+	assert.True(strings.HasPrefix(mm.Post(p.PostID).Message, p.Question+" #poll\n"))
+	time.Sleep(200 * time.Millisecond)
+	assert.Equal(1, mm.Patches())
+
+	// The final results are shown after the poll has ended
+	sendHttpRequest(require, ps, "token="+c.Token+"&user_id="+p.Creator+"&text=end "+p.ID)
+	assert.Contains(mm.Post(p.PostID).Message, "This poll has ended")
+}
+
+func TestTallyRefreshDoesNotReopenClosedPoll(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ps, mm := newTestServer(require)
+	defer mm.Close()
+	c := ps.Conf()
+	c.RefreshInterval = poll.Duration(100 * time.Millisecond)
+	p := createTestPoll(require, mm, ps, model.NewId(), ":pizza: :sushi:")
+	mm.AddReactionSilently(p.PostID, model.NewId(), "sushi")
+
+	// The first periodic refresh reads the votes slowly and the poll is ended meanwhile
+	mm.DelayReactionList(300 * time.Millisecond)
+	ps.Start()
+	defer ps.Stop()
+	time.Sleep(150 * time.Millisecond)
+	sendHttpRequest(require, ps, "token="+c.Token+"&user_id="+p.Creator+"&text=end "+p.ID)
+
+	time.Sleep(400 * time.Millisecond)
+	assert.Contains(mm.Post(p.PostID).Message, "This poll has ended")
+	stored, err := ps.Store.Get(p.ID)
+	require.Nil(err)
+	assert.Equal(poll.PollStateClosed, stored.State)
+}
+
+func TestTallyRefreshedPeriodically(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ps, mm := newTestServer(require)
+	defer mm.Close()
+	c := ps.Conf()
+	c.RefreshInterval = poll.Duration(100 * time.Millisecond)
+	ps.Start()
+	defer ps.Stop()
+
+	p := createTestPoll(require, mm, ps, model.NewId(), ":pizza: :sushi:")
+	mm.AddReactionSilently(p.PostID, model.NewId(), "sushi")
+
+	assert.True(waitFor(func() bool {
+		return strings.Contains(mm.Post(p.PostID).Message, ":sushi: `██████████` 1 (100%)")
+	}))
+	// Unchanged results aren't sent again
+	time.Sleep(300 * time.Millisecond)
+	assert.Equal(1, mm.Patches())
+}
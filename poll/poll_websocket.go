@@ -0,0 +1,173 @@
+package poll
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mattermost/mattermost-server/model"
+)
+
+// websocketRetryInterval is the time to wait before the websocket connection is opened again after it was lost
+const websocketRetryInterval = 10 * time.Second
+
+// runWebSocket watches the reactions on polls on every instance until Stop is called.
+// The connections are opened again, if the Mattermost servers or the bot credentials change.
+func (ps *Server) runWebSocket() {
+	for {
+		done := make(chan struct{})
+		var wg sync.WaitGroup
+		for _, in := range ps.Conf().instances() {
+			wg.Add(1)
+			go func(name string) {
+				defer wg.Done()
+				ps.runInstanceWebSocket(name, done)
+			}(in.Name)
+		}
+		select {
+		case <-ps.stop:
+			close(done)
+			wg.Wait()
+			return
+		case <-ps.reconnect:
+			close(done)
+			wg.Wait()
+		}
+	}
+}
+
+// runInstanceWebSocket watches the reactions on polls of the instance called name until done is closed. The connection is opened again, if it is lost.
+func (ps *Server) runInstanceWebSocket(name string, done <-chan struct{}) {
+	for {
+		lg := ps.Logger().With("instance", name)
+		if err := ps.listen(lg, name, done); err != nil {
+			lg.Error("Websocket connection failed", "err", err)
+		}
+		select {
+		case <-done:
+			return
+		case <-time.After(websocketRetryInterval):
+		}
+	}
+}
+
+// listen opens a websocket connection as the bot user of the instance called name and handles its events until the connection is lost or done is closed
+func (ps *Server) listen(lg *Logger, name string, done <-chan struct{}) error {
+	c, user, err := ps.client(lg, name)
+	if err != nil {
+		return err
+	}
+	ws, appErr := model.NewWebSocketClient4(websocketURL(c.Url), c.AuthToken)
+	if appErr != nil {
+		return fmt.Errorf("Error: Failed to connect to websocket: %v", appErr.Error())
+	}
+	defer ws.Close()
+	ws.Listen()
+	lg.Debug("Websocket connected")
+
+	for {
+		select {
+		case <-done:
+			return nil
+		case event, ok := <-ws.EventChannel:
+			if !ok {
+				if ws.ListenError != nil {
+					return fmt.Errorf("Error: Websocket connection lost: %v", ws.ListenError.Error())
+				}
+				return fmt.Errorf("Error: Websocket connection closed")
+			}
+			ps.handleEvent(lg, name, user.Id, event)
+		case response := <-ws.ResponseChannel:
+			// Responses must be drained, otherwise the websocket client blocks
+			if response != nil && response.SeqReply == 1 && response.Status == model.STATUS_FAIL {
+				// The authentication challenge failed, the session has expired
+				ps.expire(name, c)
+				return fmt.Errorf("Error: Websocket authentication failed")
+			}
+		}
+	}
+}
+
+// handleEvent reacts to votes on polls of the instance called name. Single choice polls are enforced and the results in the poll post are refreshed.
+func (ps *Server) handleEvent(lg *Logger, name string, botID string, event *model.WebSocketEvent) {
+	if event.Event != model.WEBSOCKET_EVENT_REACTION_ADDED && event.Event != model.WEBSOCKET_EVENT_REACTION_REMOVED {
+		return
+	}
+	data, ok := event.Data["reaction"].(string)
+	if !ok {
+		return
+	}
+	reaction := model.ReactionFromJson(strings.NewReader(data))
+	if reaction == nil {
+		return
+	}
+	p, err := ps.Store.GetByPost(reaction.PostId)
+	if err != nil || p.Instance != name || p.Anonymous || p.State != PollStateOpen {
+		return
+	}
+	if event.Event == model.WEBSOCKET_EVENT_REACTION_ADDED && p.Single && reaction.UserId != botID {
+		err := ps.withClient(lg, name, func(c *model.Client4, bot *model.User) error {
+			return enforceSingle(c, p, reaction)
+		})
+		if err != nil {
+			lg.Error("Failed to enforce single choice", "poll_id", p.ID, "user_id", reaction.UserId, "err", err)
+		}
+	}
+	ps.scheduleRefresh(p.ID)
+}
+
+// enforceSingle removes reaction, if it isn't an option of p, and otherwise all but the latest vote of the user who added reaction
+func enforceSingle(c *model.Client4, p *Poll, reaction *model.Reaction) error {
+	if !p.hasOption(reaction.EmojiName) {
+		return removeReaction(c, reaction)
+	}
+	reactions, apiResponse := c.GetReactions(p.PostID)
+	if err := checkResponse(apiResponse, http.StatusOK, "Failed to fetch reactions"); err != nil {
+		return err
+	}
+	var votes []*model.Reaction
+	var latest *model.Reaction
+	for _, r := range reactions {
+		if r.UserId != reaction.UserId || !p.hasOption(r.EmojiName) {
+			continue
+		}
+		votes = append(votes, r)
+		if latest == nil || r.CreateAt > latest.CreateAt {
+			latest = r
+		}
+	}
+	for _, r := range votes {
+		if r == latest {
+			continue
+		}
+		if err := removeReaction(c, r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func removeReaction(c *model.Client4, r *model.Reaction) error {
+	_, apiResponse := c.DeleteReaction(r)
+	return checkResponse(apiResponse, http.StatusOK, "Failed to remove reaction")
+}
+
+// hasOption reports whether emoji is one of the options of p
+func (p *Poll) hasOption(emoji string) bool {
+	for _, o := range p.Options {
+		if o == emoji {
+			return true
+		}
+	}
+	return false
+}
+
+// websocketURL converts the http(s) URL of a Mattermost server to its ws(s) URL
+func websocketURL(host string) string {
+	if strings.HasPrefix(host, "https://") {
+		return "wss://" + strings.TrimPrefix(host, "https://")
+	}
+	return "ws://" + strings.TrimPrefix(host, "http://")
+}
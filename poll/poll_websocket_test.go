@@ -0,0 +1,54 @@
+package poll_test
+
+import (
+	"testing"
+
+	"github.com/mattermost/mattermost-server/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSingleChoicePoll(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ps, mm := newTestServer(require)
+	defer mm.Close()
+	ps.Start()
+	defer ps.Stop()
+	require.True(waitFor(func() bool { return mm.Connected() == 1 }))
+
+	p := createTestPoll(require, mm, ps, model.NewId(), ":pizza: :sushi: --single")
+	assert.True(p.Single)
+
+	voter := model.NewId()
+	mm.AddReaction(p.PostID, voter, "pizza")
+	mm.AddReaction(p.PostID, voter, "sushi")
+	assert.True(waitFor(func() bool { return len(mm.UserReactions(p.PostID, voter)) == 1 }))
+	assert.Equal([]string{"sushi"}, mm.UserReactions(p.PostID, voter))
+
+	mm.AddReaction(p.PostID, voter, "tada")
+	assert.True(waitFor(func() bool { return len(mm.UserReactions(p.PostID, voter)) == 1 }))
+	assert.Equal([]string{"sushi"}, mm.UserReactions(p.PostID, voter))
+
+	// The initial reactions of the bot are kept
+	assert.Equal([]string{"pizza", "sushi"}, mm.UserReactions(p.PostID, mm.BotID))
+}
+
+func TestMultipleChoicePollIsNotEnforced(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ps, mm := newTestServer(require)
+	defer mm.Close()
+	ps.Start()
+	defer ps.Stop()
+	require.True(waitFor(func() bool { return mm.Connected() == 1 }))
+
+	p := createTestPoll(require, mm, ps, model.NewId(), ":pizza: :sushi:")
+	voter := model.NewId()
+	mm.AddReaction(p.PostID, voter, "pizza")
+	mm.AddReaction(p.PostID, voter, "sushi")
+	mm.AddReaction(p.PostID, voter, "tada")
+	assert.False(waitFor(func() bool { return len(mm.UserReactions(p.PostID, voter)) != 3 }))
+}